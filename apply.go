@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rabbitt/f5er/f5"
+)
+
+type planAction int
+
+const (
+	planNone planAction = iota
+	planCreate
+	planUpdate
+	planDelete
+)
+
+func (a planAction) String() string {
+	names := [...]string{"unchanged", "create", "update", "delete"}
+	return names[a]
+}
+
+func (a planAction) symbol() string {
+	symbols := [...]string{"=", "+", "~", "-"}
+	return symbols[a]
+}
+
+// a single planned change to one object of a stack
+type planStep struct {
+	*stackObject
+	Action planAction
+	Diff   string
+}
+
+// toGeneric round trips v through json so that it can be compared field by field
+func toGeneric(v interface{}) (error, interface{}) {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return err, nil
+	}
+	var res interface{}
+	err = json.Unmarshal(dat, &res)
+	return err, res
+}
+
+// project keeps only the parts of v that are also present in shape, so that
+// fields not mentioned in a stack file never show up as a difference.
+func project(v interface{}, shape interface{}) interface{} {
+	switch s := shape.(type) {
+	case map[string]interface{}:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		res := make(map[string]interface{}, len(s))
+		for key, sv := range s {
+			if mv, ok := m[key]; ok {
+				res[key] = project(mv, sv)
+			}
+		}
+		return res
+	case []interface{}:
+		a, ok := v.([]interface{})
+		if !ok || len(s) == 0 {
+			return v
+		}
+		res := make([]interface{}, len(a))
+		for i := range a {
+			if i < len(s) {
+				res[i] = project(a[i], s[i])
+			} else {
+				res[i] = project(a[i], s[len(s)-1])
+			}
+		}
+		return res
+	}
+	return v
+}
+
+// diffObject compares the fields defined in a stack object with the current
+// state of that object on the device.
+func diffObject(kind *stackKind, body json.RawMessage, existing interface{}) (error, string) {
+
+	var shape interface{}
+	if err := json.Unmarshal(body, &shape); err != nil {
+		return err, ""
+	}
+
+	// decode the stack object through the same struct the device state uses
+	// so both sides are normalised in the same way
+	desired := kind.object()
+	if err := json.Unmarshal(body, desired); err != nil {
+		return err, ""
+	}
+
+	err, want := toGeneric(desired)
+	if err != nil {
+		return err, ""
+	}
+	err, have := toGeneric(existing)
+	if err != nil {
+		return err, ""
+	}
+
+	return nil, cmp.Diff(project(have, shape), project(want, shape), cmpopts.EquateEmpty())
+}
+
+// planStack works out what needs to happen to each object in a stack for the
+// device to match it.
+func planStack(stack *LBStack, destroy bool) []*planStep {
+
+	err, objects := sortStack(stack)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	steps := make([]*planStep, 0, len(objects))
+	for _, obj := range objects {
+
+		step := &planStep{stackObject: obj}
+
+		err, existing := obj.kind.show(ctx, obj.fullPath)
+		if err != nil && !f5.IsNotFound(err) {
+			log.Fatalf("error showing %s : %s\n", obj, err)
+		}
+		exists := err == nil
+
+		switch {
+		case destroy && exists:
+			step.Action = planDelete
+		case destroy:
+			step.Action = planNone
+		case !exists:
+			step.Action = planCreate
+		default:
+			err, diff := diffObject(obj.kind, obj.body, existing)
+			if err != nil {
+				log.Fatalf("error comparing %s : %s\n", obj, err)
+			}
+			if diff != "" {
+				step.Action = planUpdate
+				step.Diff = diff
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	if destroy {
+		// remove objects in the reverse order they were created
+		for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+			steps[i], steps[j] = steps[j], steps[i]
+		}
+	}
+
+	return steps
+}
+
+func printPlan(steps []*planStep) {
+
+	counts := make(map[planAction]int)
+	for _, step := range steps {
+		counts[step.Action]++
+		fmt.Printf("%s %s %s\n", step.Action.symbol(), step.Action, step.stackObject)
+		if step.Diff != "" {
+			fmt.Printf("%s\n", step.Diff)
+		}
+	}
+	fmt.Printf("\nplan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		counts[planCreate], counts[planUpdate], counts[planDelete], counts[planNone])
+
+}
+
+func applyStep(ctx context.Context, step *planStep) error {
+
+	var err error
+	switch step.Action {
+	case planCreate:
+		err, _ = step.kind.add(ctx, &step.body)
+	case planUpdate:
+		err, _ = step.kind.update(ctx, step.fullPath, &step.body)
+	case planDelete:
+		err, _ = step.kind.remove(ctx, step.fullPath)
+	}
+	return err
+
+}
+
+// logCommitted lists earlier transactions of the same apply, which stay
+// committed when a later one fails
+func logCommitted(committed []string) {
+	if len(committed) == 0 {
+		return
+	}
+	log.Printf("%d earlier waves were already committed and are not undone:\n", len(committed))
+	for _, c := range committed {
+		log.Printf("  %s\n", c)
+	}
+}
+
+// applySteps runs a set of planned steps inside a single transaction. The
+// transaction is aborted on the first failure so none of its steps are made.
+// committed describes earlier transactions, which are logged on failure.
+func applySteps(steps []*planStep, committed []string) {
+
+	pending := 0
+	for _, step := range steps {
+		if step.Action != planNone {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return
+	}
+
+	err, tid := appliance.StartTransaction(ctx)
+	if err != nil {
+		log.Fatalf("error creating transaction: %s\n", err)
+	} else {
+		log.Printf("transaction %s created\n", tid)
+	}
+	tctx := f5.WithTransaction(ctx, tid)
+
+	for _, step := range steps {
+		if step.Action == planNone {
+			continue
+		}
+		log.Printf("%s %s\n", step.Action, step.stackObject)
+		if err := applyStep(tctx, step); err != nil {
+			logCommitted(committed)
+			abortTransaction(tid, fmt.Errorf("error during %s of %s : %s", step.Action, step.stackObject, err))
+		}
+	}
+
+	err = appliance.CommitTransaction(ctx, tid)
+	if err != nil {
+		logCommitted(committed)
+		log.Fatalf("error commiting transaction %s : %s\n", tid, err)
+	} else {
+		log.Printf("transaction committed : %s\n", tid)
+	}
+
+}
+
+func applyStack() {
+
+	stack := readStack()
+	checkStackLint(&stack)
+	steps := planStack(&stack, destroy)
+	printPlan(steps)
+
+	if planOnly {
+		return
+	}
+
+	if destroy {
+		// objects can't be deleted in the same transaction as something that
+		// still refers to them, so each wave gets a transaction of its own
+		objects := make([]*stackObject, 0, len(steps))
+		byObject := make(map[*stackObject]*planStep)
+		for i := len(steps) - 1; i >= 0; i-- {
+			objects = append(objects, steps[i].stackObject)
+			byObject[steps[i].stackObject] = steps[i]
+		}
+		waves := deleteWaves(objects)
+		committed := make([]string, 0, len(waves))
+		for i, wave := range waves {
+			waveSteps := make([]*planStep, 0, len(wave))
+			deleted := make([]string, 0, len(wave))
+			for _, obj := range wave {
+				step := byObject[obj]
+				waveSteps = append(waveSteps, step)
+				if step.Action != planNone {
+					deleted = append(deleted, step.stackObject.String())
+				}
+			}
+			if len(deleted) == 0 {
+				continue
+			}
+			log.Printf("wave %d of %d: deleting %d objects\n", i+1, len(waves), len(deleted))
+			applySteps(waveSteps, committed)
+			committed = append(committed, fmt.Sprintf("wave %d: %s", i+1, strings.Join(deleted, ", ")))
+		}
+	} else {
+		applySteps(steps, nil)
+	}
+
+}
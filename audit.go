@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// auditKind is a type of object audit orphans loads from the device
+type auditKind struct {
+	name string
+	list func(ctx context.Context) (error, interface{})
+	refs func(body json.RawMessage) []stackRef
+	// the stack kind, for objects that can be written to a stack
+	stack *stackKind
+}
+
+// certificates the device comes with
+var builtinCerts = map[string]bool{
+	"/Common/default.crt":      true,
+	"/Common/ca-bundle.crt":    true,
+	"/Common/f5-ca-bundle.crt": true,
+	"/Common/f5-irule.crt":     true,
+}
+
+// auditKinds are the stack kinds, along with the http monitors and
+// certificates they refer to
+func auditKinds() []*auditKind {
+
+	kinds := make([]*auditKind, 0, len(stackKinds)+2)
+	for _, k := range stackKinds {
+		kind := &auditKind{name: k.name, list: k.list, refs: k.refs, stack: k}
+		if k.name == "rule" {
+			kind.refs = ruleRefs
+		}
+		kinds = append(kinds, kind)
+	}
+	kinds = append(kinds,
+		&auditKind{
+			name: "monitor",
+			list: func(ctx context.Context) (error, interface{}) {
+				err, res := appliance.ShowMonitorsHttp(ctx)
+				return err, res
+			},
+			refs: func(b json.RawMessage) []stackRef {
+				obj := f5.LBMonitorHttp{}
+				json.Unmarshal(b, &obj)
+				return []stackRef{{"monitor", qualify(obj.DefaultsFrom, obj.Partition)}}
+			},
+		},
+		&auditKind{
+			name: "cert",
+			list: func(ctx context.Context) (error, interface{}) {
+				err, res := appliance.GetCertificates(ctx)
+				return err, res
+			},
+			refs: func(b json.RawMessage) []stackRef { return nil },
+		},
+	)
+	return kinds
+
+}
+
+// ruleRefs are the pools, nodes and data groups named in the TCL of a rule. A
+// pool without a partition may be in the partition of the rule or in Common,
+// so both count. Nodes are referred to by address.
+func ruleRefs(b json.RawMessage) []stackRef {
+
+	obj := f5.LBRule{}
+	json.Unmarshal(b, &obj)
+	found := f5.RuleRefs(obj.ApiAnonymous)
+	refs := []stackRef{}
+	for _, pool := range found.Pools {
+		refs = append(refs, stackRef{"pool", qualify(pool, obj.Partition)}, stackRef{"pool", qualify(pool, "Common")})
+	}
+	for _, addr := range found.Nodes {
+		refs = append(refs, stackRef{"node", addr})
+	}
+	for _, class := range found.Classes {
+		refs = append(refs, stackRef{"class", qualify(class, obj.Partition)}, stackRef{"class", qualify(class, "Common")})
+	}
+	return refs
+
+}
+
+// dynamicRules returns the rules that pick a pool or node at run time, eg.
+// pool $name, by the kind they pick. Any pool or node could be used by them.
+func dynamicRules(objects []*auditObject) map[string][]string {
+
+	dynamic := make(map[string][]string)
+	for _, obj := range objects {
+		if obj.kind.name != "rule" {
+			continue
+		}
+		rule := f5.LBRule{}
+		json.Unmarshal(obj.body, &rule)
+		found := f5.RuleRefs(rule.ApiAnonymous)
+		if found.DynamicPools {
+			dynamic["pool"] = append(dynamic["pool"], obj.fullPath)
+		}
+		if found.DynamicNodes {
+			dynamic["node"] = append(dynamic["node"], obj.fullPath)
+		}
+	}
+	return dynamic
+
+}
+
+// auditObject is an object loaded from the device
+type auditObject struct {
+	kind     *auditKind
+	fullPath string
+	body     json.RawMessage
+}
+
+// identity is the part of an object that names it
+type identity struct {
+	Name         string `json:"name"`
+	Partition    string `json:"partition"`
+	FullPath     string `json:"fullPath"`
+	DefaultsFrom string `json:"defaultsFrom"`
+}
+
+// builtin reports whether the object came with the device, so is never an
+// orphan - eg. the base profiles and monitors others are made from
+func (o *auditObject) builtin(id identity) bool {
+	switch o.kind.name {
+	case "client-ssl", "server-ssl", "monitor":
+		return id.DefaultsFrom == "" || id.DefaultsFrom == "none"
+	case "rule":
+		return strings.HasPrefix(id.Name, "_sys_")
+	case "cert":
+		return builtinCerts[o.fullPath]
+	}
+	return false
+}
+
+// loadAudit lists every object of each kind on the device
+func loadAudit(kinds []*auditKind) []*auditObject {
+
+	objects := make([]*auditObject, 0)
+	for _, kind := range kinds {
+		err, res := kind.list(ctx)
+		if err != nil {
+			log.Fatalf("error listing %s objects: %s\n", kind.name, err)
+		}
+		// every list has its objects under items
+		dat, err := json.Marshal(res)
+		if err != nil {
+			log.Fatalf("error reading %s objects: %s\n", kind.name, err)
+		}
+		list := struct {
+			Items []json.RawMessage `json:"items"`
+		}{}
+		if err := json.Unmarshal(dat, &list); err != nil {
+			log.Fatalf("error reading %s objects: %s\n", kind.name, err)
+		}
+		for _, body := range list.Items {
+			id := identity{}
+			json.Unmarshal(body, &id)
+			obj := &auditObject{kind: kind, fullPath: objectPath(id.FullPath, id.Name, id.Partition, ""), body: body}
+			if !obj.builtin(id) {
+				objects = append(objects, obj)
+			}
+		}
+	}
+	return objects
+
+}
+
+// findOrphans returns the objects nothing else refers to, in partition if
+// given. Virtuals are where references start, so are never orphans.
+func findOrphans(objects []*auditObject, partition string) []*auditObject {
+
+	referenced := make(map[stackRef]bool)
+	for _, obj := range objects {
+		for _, ref := range obj.kind.refs(obj.body) {
+			if ref.path != obj.fullPath || ref.kind != obj.kind.name {
+				referenced[ref] = true
+			}
+		}
+	}
+
+	orphans := make([]*auditObject, 0)
+	for _, obj := range objects {
+		if obj.kind.name == "virtual" || referenced[stackRef{obj.kind.name, obj.fullPath}] {
+			continue
+		}
+		if obj.kind.name == "node" {
+			// rules send to a node by its address
+			node := f5.LBNode{}
+			json.Unmarshal(obj.body, &node)
+			if node.Address != "" && referenced[stackRef{"node", node.Address}] {
+				continue
+			}
+		}
+		if partition != "" && !strings.HasPrefix(obj.fullPath, "/"+strings.Trim(partition, "/")+"/") {
+			continue
+		}
+		orphans = append(orphans, obj)
+	}
+	sort.SliceStable(orphans, func(i, j int) bool {
+		if orphans[i].kind.name != orphans[j].kind.name {
+			return orphans[i].kind.name < orphans[j].kind.name
+		}
+		return orphans[i].fullPath < orphans[j].fullPath
+	})
+	return orphans
+
+}
+
+// orphanStack builds a stack of the orphans that can be deleted with delete
+// stack, leaving out the kinds in skip. Only the names of each object are kept.
+func orphanStack(orphans []*auditObject, skip map[string][]string) LBStack {
+
+	byKind := make(map[string][]json.RawMessage)
+	for _, kind := range stackKinds {
+		byKind[kind.name] = make([]json.RawMessage, 0)
+	}
+	for _, obj := range orphans {
+		if obj.kind.stack == nil || len(skip[obj.kind.name]) > 0 {
+			continue
+		}
+		id := identity{}
+		json.Unmarshal(obj.body, &id)
+		body, _ := json.Marshal(struct {
+			Name      string `json:"name"`
+			Partition string `json:"partition"`
+			FullPath  string `json:"fullPath"`
+		}{id.Name, id.Partition, obj.fullPath})
+		byKind[obj.kind.name] = append(byKind[obj.kind.name], body)
+	}
+	return LBStack{
+		ServerSsl: byKind["server-ssl"],
+		ClientSsl: byKind["client-ssl"],
+		Nodes:     byKind["node"],
+		Pools:     byKind["pool"],
+		Rules:     byKind["rule"],
+		Policies:  byKind["policy"],
+		Virtuals:  byKind["virtual"],
+	}
+
+}
+
+// auditOrphans reports every object nothing refers to, and with --emit-stack
+// writes them to a stack file for delete stack. Pools and nodes are left out of
+// the stack when a rule picks them at run time.
+func auditOrphans() {
+
+	objects := loadAudit(auditKinds())
+	orphans := findOrphans(objects, listPartition)
+	if len(orphans) == 0 {
+		fmt.Println("no orphaned objects")
+		return
+	}
+	dynamic := dynamicRules(objects)
+
+	// keep stdout for the stack when it is written there
+	out := os.Stdout
+	if emitStack == "-" {
+		out = os.Stderr
+	}
+	unstackable := 0
+	for _, obj := range orphans {
+		fmt.Fprintf(out, "%s %s\n", obj.kind.name, obj.fullPath)
+		if obj.kind.stack == nil || len(dynamic[obj.kind.name]) > 0 {
+			unstackable++
+		}
+	}
+	fmt.Fprintf(out, "%d orphaned objects\n", len(orphans))
+	for _, kind := range []string{"pool", "node"} {
+		if rules := dynamic[kind]; len(rules) > 0 {
+			fmt.Fprintf(out, "warning: rules %s pick a %s at run time, so any %s may be in use and none are in the stack\n", strings.Join(rules, ", "), kind, kind)
+		}
+	}
+
+	if emitStack == "" {
+		return
+	}
+	dat, err := json.MarshalIndent(orphanStack(orphans, dynamic), "", "  ")
+	if err != nil {
+		log.Fatalf("error building stack: %s\n", err)
+	}
+	if emitStack == "-" {
+		fmt.Println(string(dat))
+	} else if err := ioutil.WriteFile(emitStack, append(dat, '\n'), 0644); err != nil {
+		log.Fatalf("error writing stack file: %s\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "stack of %d orphans written to %s\n", len(orphans)-unstackable, emitStack)
+	}
+	if unstackable > 0 {
+		fmt.Fprintf(os.Stderr, "%d orphans are not in the stack, delete them by hand once checked\n", unstackable)
+	}
+
+}
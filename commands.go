@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -25,6 +26,9 @@ var f5Cmd = &cobra.Command{
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		checkFlags(cmd)
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		afterCommand(cmd)
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -85,8 +89,8 @@ var deleteCmd = &cobra.Command{
 
 var offlineCmd = &cobra.Command{
 	Use:   "offline",
-	Short: "offline a pool member",
-	Long:  "offline an F5 pool member. eg. f5er offline poolmember /partition/poolmember",
+	Short: "offline a pool member or node",
+	Long:  "offline an F5 pool member or node. eg. f5er offline poolmember /partition/poolmember",
 	Run: func(cmd *cobra.Command, args []string) {
 		offline()
 	},
@@ -94,13 +98,189 @@ var offlineCmd = &cobra.Command{
 
 var onlineCmd = &cobra.Command{
 	Use:   "online",
-	Short: "online a pool member",
-	Long:  "online an F5 pool member. eg. f5er online poolmember /partition/poolmember",
+	Short: "online a pool member or node",
+	Long:  "online an F5 pool member or node. eg. f5er online poolmember /partition/poolmember",
 	Run: func(cmd *cobra.Command, args []string) {
 		online()
 	},
 }
 
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "drain a pool member",
+	Long:  "drain the connections of an F5 pool member. eg. f5er drain poolmember --pool /partition/pool /partition/poolmember",
+	Run: func(cmd *cobra.Command, args []string) {
+		drain()
+	},
+}
+
+var rolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "roll a deployment through a pool",
+	Long:  "take the members of a pool out of service a batch at a time, running a command for each. eg. f5er rollout --pool /partition/pool --batch 2 --exec \"deploy.sh {member}\"",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkRequiredFlag("pool")
+		rolloutPool(f5Pool)
+	},
+}
+
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "wait for an F5 object to reach a state",
+	Long:  "wait until a virtual, pool, pool member or node is available, or another state. eg. f5er wait virtual /partition/virtual",
+	Run: func(cmd *cobra.Command, args []string) {
+		wait()
+	},
+}
+
+var waitVirtualCmd = &cobra.Command{
+	Use:   "virtual",
+	Short: "wait for a virtual server",
+	Long:  "wait until a virtual server reaches a state",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("wait virtual requires a virtual server name as an argument (ie /partition/virtual )")
+		}
+		waitFor(f5.LBObjectRef{Kind: "virtual", Name: args[0]})
+	},
+}
+
+var waitPoolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "wait for a pool",
+	Long:  "wait until a pool reaches a state",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("wait pool requires a pool name as an argument (ie /partition/pool )")
+		}
+		waitFor(f5.LBObjectRef{Kind: "pool", Name: args[0]})
+	},
+}
+
+var waitPoolMemberCmd = &cobra.Command{
+	Use:   "poolmember",
+	Short: "wait for a poolmember",
+	Long:  "wait until a poolmember reaches a state",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkRequiredFlag("pool")
+		if len(args) < 1 {
+			log.Fatal("wait poolmember requires a poolmember name as an argument (ie /partition/poolmember )")
+		}
+		waitFor(f5.LBObjectRef{Kind: "poolmember", Pool: f5Pool, Name: args[0]})
+	},
+}
+
+var waitNodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "wait for a node",
+	Long:  "wait until a node reaches a state",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("wait node requires a node name as an argument (ie /partition/node )")
+		}
+		waitFor(f5.LBObjectRef{Kind: "node", Name: args[0]})
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "check F5 objects offline",
+	Long:  "check F5 objects without a device. eg. f5er lint rule rule.tcl",
+	Run: func(cmd *cobra.Command, args []string) {
+		lint()
+	},
+	// linting needs no device, so skip the device flags and login
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+var lintRuleCmd = &cobra.Command{
+	Use:   "rule",
+	Short: "lint the tcl of rules",
+	Long:  "check tcl files for unbalanced braces and quotes, unknown events, deprecated commands and common mistakes.\nExits non-zero if any have errors. eg. f5er lint rule rules/*.tcl",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("lint rule requires tcl files as arguments")
+		}
+		lintRuleFiles(args)
+	},
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "report on F5 objects",
+	Long:  "report on how F5 objects are used. eg. f5er report rules",
+	Run: func(cmd *cobra.Command, args []string) {
+		report()
+	},
+}
+
+var reportRulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "profile rules",
+	Long:  "rank rules by the cpu cycles they have used, with the virtuals they are attached to.\nAlso lists rules with failures or aborts and rules that are attached nowhere or have never run",
+	Run: func(cmd *cobra.Command, args []string) {
+		reportRules()
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "audit F5 objects",
+	Long:  "audit the objects on a device. eg. f5er audit orphans --partition DMZ",
+	Run: func(cmd *cobra.Command, args []string) {
+		audit()
+	},
+}
+
+var auditOrphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "find unreferenced objects",
+	Long:  "find pools, nodes, policies, rules, ssl profiles, http monitors and certificates that nothing refers to.\nWith --emit-stack the orphans are written to a stack file for delete stack",
+	Run: func(cmd *cobra.Command, args []string) {
+		auditOrphans()
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate F5 objects",
+	Long:  "check F5 objects before they are sent to the device. eg. f5er validate stack -i stack.json",
+	Run: func(cmd *cobra.Command, args []string) {
+		validate()
+	},
+}
+
+var validateStackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "validate a stack",
+	Long:  "check that every reference in a stack is to an object in the stack or on the device, that names and partitions\nmatch full paths and that route domains agree. Exits non-zero with a list of problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkRequiredFlag("input")
+		validateStack()
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export F5 objects to files",
+	Long:  "export F5 objects to files. eg. f5er export rule /partition/rulename",
+	Run: func(cmd *cobra.Command, args []string) {
+		export()
+	},
+}
+
+var exportRuleCmd = &cobra.Command{
+	Use:   "rule",
+	Short: "export a rule",
+	Long:  "write the tcl of a rule to a file, named after the rule unless given with --output",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("export rule requires a rule name as an argument (ie /partition/rulename )")
+		}
+		exportRule(args[0])
+	},
+}
+
 var statsCmd = &cobra.Command{
 	Use:   "stats",
 	Short: "get F5 statistics",
@@ -118,7 +298,7 @@ var showDeviceCmd = &cobra.Command{
 	Short: "show an f5 device",
 	Long:  "show the current state of an f5 device",
 	Run: func(cmd *cobra.Command, args []string) {
-		err, res := appliance.ShowDevice()
+		err, res := appliance.ShowDevice(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -126,22 +306,74 @@ var showDeviceCmd = &cobra.Command{
 	},
 }
 
+var showFailoverStatusCmd = &cobra.Command{
+	Use:   "failover-status",
+	Short: "show the failover status of an f5 device",
+	Long:  "show whether an f5 device is the active or standby unit of its cluster",
+	Run: func(cmd *cobra.Command, args []string) {
+		err, res := appliance.FailoverStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		appliance.PrintObject(res)
+	},
+}
+
+var showSyncStatusCmd = &cobra.Command{
+	Use:   "sync-status",
+	Short: "show the config sync status of an f5 device",
+	Long:  "show whether the configuration of an f5 device is in sync with its device group",
+	Run: func(cmd *cobra.Command, args []string) {
+		err, res := appliance.SyncStatus(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		appliance.PrintObject(res)
+	},
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [device-group]",
+	Short: "config-sync a device group",
+	Long:  "push the configuration of the active unit to its device group and wait until the group is in sync",
+	Run: func(cmd *cobra.Command, args []string) {
+		group := ""
+		if len(args) > 0 {
+			group = args[0]
+		}
+		syncConfig(group)
+	},
+}
+
+var serveMetricsCmd = &cobra.Command{
+	Use:   "serve-metrics",
+	Short: "serve F5 statistics to prometheus",
+	Long:  "run an exporter serving the statistics of pools, pool members, virtual servers, nodes and rules on /metrics in the prometheus text format",
+	Run: func(cmd *cobra.Command, args []string) {
+		serveMetrics()
+	},
+}
+
 var showPoolCmd = &cobra.Command{
 	Use:   "pool",
 	Short: "show a pool",
 	Long:  "show the current state of a pool",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowPools()
+			err, res := appliance.ShowPools(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowPool(name)
+			err, res := appliance.ShowPool(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -167,7 +399,7 @@ var addPoolCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddPool(&body)
+		err, res := appliance.AddPool(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -197,7 +429,7 @@ var updatePoolCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdatePool(pname, &body)
+			err, res := appliance.UpdatePool(ctx, pname, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -229,7 +461,7 @@ var patchPoolCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchPool(pname, &patch)
+			err, res := appliance.PatchPool(ctx, pname, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -248,7 +480,7 @@ var deletePoolCmd = &cobra.Command{
 			log.Fatal("delete pool requires a pool name as an argument (ie /partition/poolname )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeletePool(name)
+			err, res := appliance.DeletePool(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -264,22 +496,12 @@ var statsPoolCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			err, res := appliance.StatsPools()
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(appliance.StatsPools)
 		} else {
 			name := args[0]
-			err, res := appliance.StatsPool(name)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(func(ctx context.Context) (error, []f5.Metric) {
+				return appliance.StatsPool(ctx, name)
+			})
 		}
 	},
 }
@@ -296,10 +518,14 @@ var showPoolMemberCmd = &cobra.Command{
 			log.Fatal("show poolmember requires a pool as an argument - in the form of /partition/poolname")
 		} else {
 			name := args[0]
-			err, res := appliance.ShowPoolMembers(name)
+			err, res := appliance.ShowPoolMembers(ctx, name, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			appliance.PrintObject(res.Items)
 		}
 	},
@@ -326,7 +552,7 @@ var addPoolMemberCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.AddPoolMembers(name, &body)
+			err, res := appliance.AddPoolMembers(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -356,7 +582,7 @@ var updatePoolMemberCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdatePoolMembers(name, &body)
+			err, res := appliance.UpdatePoolMembers(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -375,7 +601,7 @@ var deletePoolMemberCmd = &cobra.Command{
 			log.Fatal("delete poolmember requires a pool name as an argument (ie /partition/poolname )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeletePoolMembers(name)
+			err, res := appliance.DeletePoolMembers(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -394,7 +620,7 @@ var offlinePoolMemberCmd = &cobra.Command{
 			log.Fatal("offline poolmember requires a poolmember name as an argument (ie /partition/poolmember )")
 		} else {
 			name := args[0]
-			err, res := appliance.OfflinePoolMember(f5Pool, name)
+			err, res := appliance.OfflinePoolMember(ctx, f5Pool, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -413,7 +639,7 @@ var onlinePoolMemberCmd = &cobra.Command{
 			log.Fatal("online poolmember requires a poolmember name as an argument (ie /partition/poolmember )")
 		} else {
 			name := args[0]
-			err, res := appliance.OnlinePoolMember(f5Pool, name)
+			err, res := appliance.OnlinePoolMember(ctx, f5Pool, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -422,6 +648,46 @@ var onlinePoolMemberCmd = &cobra.Command{
 	},
 }
 
+var offlineNodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "offline a node",
+	Long:  "disable a node and its member in every pool, given its name or address",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("offline node requires a node name or address as an argument (ie /partition/node or 10.1.1.1 )")
+		} else {
+			setNodeState(args[0], false)
+		}
+	},
+}
+
+var onlineNodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "online a node",
+	Long:  "enable a node and its member in every pool, given its name or address",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 {
+			log.Fatal("online node requires a node name or address as an argument (ie /partition/node or 10.1.1.1 )")
+		} else {
+			setNodeState(args[0], true)
+		}
+	},
+}
+
+var drainPoolMemberCmd = &cobra.Command{
+	Use:   "poolmember",
+	Short: "drain a poolmember",
+	Long:  "disable a poolmember and wait for its connections to drop, then optionally force it offline",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkRequiredFlag("pool")
+		if len(args) < 1 {
+			log.Fatal("drain poolmember requires a poolmember name as an argument (ie /partition/poolmember )")
+		} else {
+			drainPoolMember(f5Pool, args[0])
+		}
+	},
+}
+
 var statsPoolMembersCmd = &cobra.Command{
 	Use:   "poolmember",
 	Short: "show poolmember statistics",
@@ -429,22 +695,12 @@ var statsPoolMembersCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			err, res := appliance.StatsCommonPoolMembers()
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(appliance.StatsCommonPoolMembers)
 		} else {
 			name := args[0]
-			err, res := appliance.StatsPoolMembers(name)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(func(ctx context.Context) (error, []f5.Metric) {
+				return appliance.StatsPoolMembers(ctx, name)
+			})
 		}
 	},
 }
@@ -455,16 +711,20 @@ var showVirtualCmd = &cobra.Command{
 	Long:  "show the current state of a virtual server",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowVirtuals()
+			err, res := appliance.ShowVirtuals(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowVirtual(name)
+			err, res := appliance.ShowVirtual(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -491,7 +751,7 @@ var addVirtualCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		err, res := appliance.AddVirtual(&body)
+		err, res := appliance.AddVirtual(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -520,7 +780,7 @@ var updateVirtualCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdateVirtual(name, &body)
+			err, res := appliance.UpdateVirtual(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -555,7 +815,7 @@ var patchVirtualCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchVirtual(name, &patch)
+			err, res := appliance.PatchVirtual(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -573,7 +833,7 @@ var deleteVirtualCmd = &cobra.Command{
 			log.Fatal("delete virtual requires a virtual server name as an argument (ie /partition/virtualservername )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteVirtual(name)
+			err, res := appliance.DeleteVirtual(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -589,22 +849,12 @@ var statsVirtualCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			err, res := appliance.StatsVirtuals()
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(appliance.StatsVirtuals)
 		} else {
 			name := args[0]
-			err, res := appliance.StatsVirtual(name)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(func(ctx context.Context) (error, []f5.Metric) {
+				return appliance.StatsVirtual(ctx, name)
+			})
 		}
 	},
 }
@@ -615,16 +865,20 @@ var showPolicyCmd = &cobra.Command{
 	Long:  "show the current state of a policy",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowPolicies()
+			err, res := appliance.ShowPolicies(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowPolicy(name)
+			err, res := appliance.ShowPolicy(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -650,7 +904,7 @@ var addPolicyCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddPolicy(&body)
+		err, res := appliance.AddPolicy(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -679,7 +933,7 @@ var updatePolicyCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdatePolicy(name, &body)
+			err, res := appliance.UpdatePolicy(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -713,7 +967,7 @@ var patchPolicyCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchPolicy(name, &patch)
+			err, res := appliance.PatchPolicy(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -731,7 +985,7 @@ var deletePolicyCmd = &cobra.Command{
 			log.Fatal("delete policy requires a policy name as an argument (ie /partition/policy )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeletePolicy(name)
+			err, res := appliance.DeletePolicy(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -746,16 +1000,20 @@ var showNodeCmd = &cobra.Command{
 	Long:  "show the current state of a node",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowNodes()
+			err, res := appliance.ShowNodes(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowNode(name)
+			err, res := appliance.ShowNode(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -781,7 +1039,7 @@ var addNodeCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddNode(&body)
+		err, res := appliance.AddNode(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -810,7 +1068,7 @@ var updateNodeCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdateNode(name, &body)
+			err, res := appliance.UpdateNode(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -844,7 +1102,7 @@ var patchNodeCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchNode(name, &patch)
+			err, res := appliance.PatchNode(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -862,7 +1120,7 @@ var deleteNodeCmd = &cobra.Command{
 			log.Fatal("delete node requires a node name as an argument (ie /partition/nodename )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteNode(name)
+			err, res := appliance.DeleteNode(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -878,22 +1136,12 @@ var statsNodeCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			err, res := appliance.StatsNodes()
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(appliance.StatsNodes)
 		} else {
 			name := args[0]
-			err, res := appliance.StatsNode(name)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(func(ctx context.Context) (error, []f5.Metric) {
+				return appliance.StatsNode(ctx, name)
+			})
 		}
 	},
 }
@@ -904,16 +1152,20 @@ var showRuleCmd = &cobra.Command{
 	Long:  "show the details of a rule",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowRules()
+			err, res := appliance.ShowRules(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowRule(name)
+			err, res := appliance.ShowRule(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -925,8 +1177,21 @@ var showRuleCmd = &cobra.Command{
 var addRuleCmd = &cobra.Command{
 	Use:   "rule",
 	Short: "add a rule",
-	Long:  "add a new rule",
+	Long:  "add a new rule, from json or with --tcl from a tcl file. eg. f5er add rule /partition/rulename --tcl rule.tcl",
 	Run: func(cmd *cobra.Command, args []string) {
+		if ruleTcl != "" {
+			if len(args) < 1 {
+				log.Fatal("add rule --tcl requires a rule name as an argument (ie /partition/rulename )")
+			}
+			tcl := readTcl(ruleTcl)
+			checkLint(ruleTcl, string(tcl))
+			err, res := appliance.AddRuleTcl(ctx, args[0], tcl)
+			if err != nil {
+				log.Fatal(err)
+			}
+			appliance.PrintObject(res)
+			return
+		}
 		checkRequiredFlag("input")
 		body := json.RawMessage{}
 		// read in input file
@@ -939,7 +1204,12 @@ var addRuleCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddRule(&body)
+		rule := f5.LBRule{}
+		if err := json.Unmarshal(body, &rule); err != nil {
+			log.Fatalf("error parsing rule %s : %s\n", f5Input, err)
+		}
+		checkLint(f5Input, rule.ApiAnonymous)
+		err, res := appliance.AddRule(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -950,12 +1220,20 @@ var addRuleCmd = &cobra.Command{
 var updateRuleCmd = &cobra.Command{
 	Use:   "rule",
 	Short: "update a rule",
-	Long:  "update an existing F5 rule",
+	Long:  "update an existing F5 rule, from json or with --tcl from a tcl file",
 	Run: func(cmd *cobra.Command, args []string) {
-		checkRequiredFlag("input")
 		if len(args) < 1 {
 			log.Fatal("update rule requires a rule name as an argument (ie /partition/rulename )")
+		} else if ruleTcl != "" {
+			tcl := readTcl(ruleTcl)
+			checkLint(ruleTcl, string(tcl))
+			err, res := appliance.UpdateRuleTcl(ctx, args[0], tcl)
+			if err != nil {
+				log.Fatal(err)
+			}
+			appliance.PrintObject(res)
 		} else {
+			checkRequiredFlag("input")
 			name := args[0]
 			body := json.RawMessage{}
 			// read in input file
@@ -968,7 +1246,12 @@ var updateRuleCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdateRule(name, &body)
+			rule := f5.LBRule{}
+			if err := json.Unmarshal(body, &rule); err != nil {
+				log.Fatalf("error parsing rule %s : %s\n", f5Input, err)
+			}
+			checkLint(f5Input, rule.ApiAnonymous)
+			err, res := appliance.UpdateRule(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -986,7 +1269,7 @@ var deleteRuleCmd = &cobra.Command{
 			log.Fatal("delete rule requires a rule name as an argument (ie /partition/rulename )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteRule(name)
+			err, res := appliance.DeleteRule(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1002,33 +1285,86 @@ var statsRuleCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) < 1 {
-			err, res := appliance.StatsRules()
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(appliance.StatsRules)
 		} else {
 			name := args[0]
-			err, res := appliance.StatsRule(name)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for _, datapoint := range res {
-				fmt.Printf("%s\n", datapoint.String())
-			}
+			collectStats(func(ctx context.Context) (error, []f5.Metric) {
+				return appliance.StatsRule(ctx, name)
+			})
 		}
 	},
 }
 
+var statsSystemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "show system statistics",
+	Long:  "show the cpu, memory, disk, throughput and connection statistics of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsSystem)
+	},
+}
+
+var statsAllCmd = &cobra.Command{
+	Use:   "all",
+	Short: "show all statistics",
+	Long:  "show the statistics of every pool, pool member, virtual server, node and rule and of the system, fetching several at once",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.Stats)
+	},
+}
+
+var statsCpuCmd = &cobra.Command{
+	Use:   "cpu",
+	Short: "show cpu statistics",
+	Long:  "show the usage of each cpu of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsCpu)
+	},
+}
+
+var statsMemoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "show memory statistics",
+	Long:  "show the memory usage of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsMemory)
+	},
+}
+
+var statsDiskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "show disk statistics",
+	Long:  "show the size and free space of each logical disk of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsDisk)
+	},
+}
+
+var statsThroughputCmd = &cobra.Command{
+	Use:   "throughput",
+	Short: "show throughput statistics",
+	Long:  "show the throughput of the device in bits and packets",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsThroughput)
+	},
+}
+
+var statsConnectionsCmd = &cobra.Command{
+	Use:   "connections",
+	Short: "show connection statistics",
+	Long:  "show the client and server connections of the device",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectStats(appliance.StatsConnections)
+	},
+}
+
 var showProfileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "show profiles",
 	Long:  "show profiles .\nProvide a profile type or a profile name with the full path like so: server-ssl/~partition~custom_server_ssl_name",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowProfiles()
+			err, res := appliance.ShowProfiles(ctx)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1037,7 +1373,7 @@ var showProfileCmd = &cobra.Command{
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowProfile(name)
+			err, res := appliance.ShowProfile(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1052,16 +1388,20 @@ var showServerSslCmd = &cobra.Command{
 	Long:  "show the details of a server-ssl profile",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowServerSsls()
+			err, res := appliance.ShowServerSsls(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowServerSsl(name)
+			err, res := appliance.ShowServerSsl(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1087,7 +1427,7 @@ var addServerSslCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddServerSsl(&body)
+		err, res := appliance.AddServerSsl(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1116,7 +1456,7 @@ var updateServerSslCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdateServerSsl(name, &body)
+			err, res := appliance.UpdateServerSsl(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1150,7 +1490,7 @@ var patchServerSslCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchServerSsl(name, &patch)
+			err, res := appliance.PatchServerSsl(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1168,7 +1508,7 @@ var deleteServerSslCmd = &cobra.Command{
 			log.Fatal("delete server-ssl requires a server-ssl profile name as an argument (ie /partition/profilename )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteServerSsl(name)
+			err, res := appliance.DeleteServerSsl(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1183,16 +1523,20 @@ var showClientSslCmd = &cobra.Command{
 	Long:  "show the details of a client-ssl profile",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowClientSsls()
+			err, res := appliance.ShowClientSsls(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowClientSsl(name)
+			err, res := appliance.ShowClientSsl(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1218,7 +1562,7 @@ var addClientSslCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddClientSsl(&body)
+		err, res := appliance.AddClientSsl(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1247,7 +1591,7 @@ var updateClientSslCmd = &cobra.Command{
 				log.Fatal(err)
 			}
 			name := args[0]
-			err, res := appliance.UpdateClientSsl(name, &body)
+			err, res := appliance.UpdateClientSsl(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1281,7 +1625,7 @@ var patchClientSslCmd = &cobra.Command{
 				log.Fatal(err)
 			}
 			name := args[0]
-			err, res := appliance.PatchClientSsl(name, &patch)
+			err, res := appliance.PatchClientSsl(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1299,7 +1643,7 @@ var deleteClientSslCmd = &cobra.Command{
 			log.Fatal("delete client-ssl requires a client-ssl profile name as an argument (ie /partition/profilename )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteClientSsl(name)
+			err, res := appliance.DeleteClientSsl(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1314,16 +1658,20 @@ var showMonitorHttpCmd = &cobra.Command{
 	Long:  "show the details of a monitor-http profile",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) < 1 {
-			err, res := appliance.ShowMonitorsHttp()
+			err, res := appliance.ShowMonitorsHttp(ctx, listOptions())
 			if err != nil {
 				log.Fatal(err)
 			}
+			if len(listFields) > 0 {
+				printFields(res.Items)
+				return
+			}
 			for _, v := range res.Items {
 				fmt.Printf("%s\n", v.FullPath)
 			}
 		} else {
 			name := args[0]
-			err, res := appliance.ShowMonitorHttp(name)
+			err, res := appliance.ShowMonitorHttp(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1349,7 +1697,7 @@ var addMonitorHttpCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal(err)
 		}
-		err, res := appliance.AddMonitorHttp(&body)
+		err, res := appliance.AddMonitorHttp(ctx, &body)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1378,7 +1726,7 @@ var updateMonitorHttpCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.UpdateMonitorHttp(name, &body)
+			err, res := appliance.UpdateMonitorHttp(ctx, name, &body)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1412,7 +1760,7 @@ var patchMonitorHttpCmd = &cobra.Command{
 			if err != nil {
 				log.Fatal(err)
 			}
-			err, res := appliance.PatchMonitorHttp(name, &patch)
+			err, res := appliance.PatchMonitorHttp(ctx, name, &patch)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1430,7 +1778,7 @@ var deleteMonitorHttpCmd = &cobra.Command{
 			log.Fatal("delete monitor-http requires a monitor-http profile name as an argument (ie /partition/profilename )")
 		} else {
 			name := args[0]
-			err, res := appliance.DeleteMonitorHttp(name)
+			err, res := appliance.DeleteMonitorHttp(ctx, name)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -1493,6 +1841,16 @@ var deleteStackCmd = &cobra.Command{
 	},
 }
 
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "apply a stack",
+	Long:  "compare a stack with the device and create, update or delete objects so they match.\nUse --plan to only show the changes, eg. f5er apply --plan -i stack.json\nWith --destroy objects are deleted in waves, each in a transaction of its own, so a failed\nwave leaves the waves before it deleted.",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkRequiredFlag("input")
+		applyStack()
+	},
+}
+
 var uploadFileCmd = &cobra.Command{
 	Use:   "upload",
 	Short: "upload a file",
@@ -1504,7 +1862,7 @@ var uploadFileCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 		fmt.Println("Uploading file", filepath.Base(filename))
-		err = appliance.UploadFile(filepath.Base(filename), dat)
+		err = appliance.UploadFile(ctx, filepath.Base(filename), dat)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1531,7 +1889,7 @@ var showCertCmd = &cobra.Command{
 		}
 		partition := args[0]
 		cert_name := args[1]
-		err, cert := appliance.GetCertificate(partition, cert_name)
+		err, cert := appliance.GetCertificate(ctx, partition, cert_name)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1544,10 +1902,14 @@ var showCertsCmd = &cobra.Command{
 	Short: "show all certificates",
 	Long:  "show all certificates",
 	Run: func(cmd *cobra.Command, args []string) {
-		err, certs := appliance.GetCertificates()
+		err, certs := appliance.GetCertificates(ctx, listOptions())
 		if err != nil {
 			log.Fatal(err)
 		}
+		if len(listFields) > 0 {
+			printFields(certs.Items)
+			return
+		}
 		for _, cert := range certs.Items {
 			fmt.Println("")
 			PrintCerts(&cert)
@@ -1563,7 +1925,7 @@ var addCertCmd = &cobra.Command{
 		if len(args) != 3 {
 			log.Fatal("[cert_name, partition, local_file]")
 		}
-		err, cert := appliance.CreateCertificateFromLocalFile(args[0], args[1], args[2])
+		err, cert := appliance.CreateCertificateFromLocalFile(ctx, args[0], args[1], args[2])
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1579,7 +1941,7 @@ var addKeyCmd = &cobra.Command{
 		if len(args) != 3 {
 			log.Fatal("[key_name, partition, local_file]")
 		}
-		err, cert := appliance.CreateKeyFromLocalFile(args[0], args[1], args[2])
+		err, cert := appliance.CreateKeyFromLocalFile(ctx, args[0], args[1], args[2])
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1595,7 +1957,7 @@ var runCmd = &cobra.Command{
 		if len(args) != 1 {
 			log.Fatal("Too little or too many arguements, Example: f5 run \"ls -al\"")
 		}
-		err, res := appliance.Run(args[0])
+		err, res := appliance.Run(ctx, args[0])
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -1605,7 +1967,7 @@ var runCmd = &cobra.Command{
 
 func show() {
 
-	err, mods := appliance.ShowModules()
+	err, mods := appliance.ShowModules(ctx)
 	if err != nil {
 		log.Fatalf("cannot show modules: %s\n", err)
 	}
@@ -1632,25 +1994,51 @@ func delete() {
 }
 
 func offline() {
-	fmt.Println("which pool member would you like to offline?")
+	fmt.Println("which pool member or node would you like to offline?")
 }
 
 func online() {
-	fmt.Println("which pool member would you like to online?")
+	fmt.Println("which pool member or node would you like to online?")
+}
+
+func drain() {
+	fmt.Println("which pool member would you like to drain?")
+}
+
+func wait() {
+	fmt.Println("what sort of F5 object would you like to wait for? (virtual, pool, poolmember or node)")
+}
+
+func lint() {
+	fmt.Println("what sort of F5 object would you like to lint? (rule)")
+}
+
+func report() {
+	fmt.Println("what would you like a report on? (rules)")
+}
+
+func audit() {
+	fmt.Println("what would you like to audit? (orphans)")
+}
+
+func validate() {
+	fmt.Println("what would you like to validate? (stack)")
+}
+
+func export() {
+	fmt.Println("what sort of F5 object would you like to export? (rule)")
 }
 
 func stats() {
 
-	fmt.Println("what sort of F5 object would you like stats for? (virtual, pool, node or rule)")
+	fmt.Println("what sort of F5 object would you like stats for? (virtual, pool, poolmember, node, rule, system, cpu, memory, disk, throughput, connections or all)")
 
 	/*
-		err, res := appliance.Stats()
+		err, res := appliance.Stats(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, datapoint := range res {
-			fmt.Printf("%s\n", datapoint.String())
-		}
+		printStats(res)
 		if err != nil {
 			log.Fatalf("cannot get statistics: %s\n", err)
 		}
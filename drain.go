@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// drainPoolMember disables a pool member so it takes no new connections,
+// waits for its connections to drop to --threshold and then, with --force,
+// forces it offline. Giving up after --timeout is fatal and leaves the member
+// disabled.
+func drainPoolMember(pool string, member string) {
+
+	log.Printf("disabling pool member %s in pool %s\n", member, pool)
+	err, _ := appliance.OfflinePoolMember(ctx, pool, member)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, drainTimeout)
+	defer cancel()
+	err, _ = appliance.WaitForDrain(wctx, pool, member, float64(drainThreshold), drainInterval, func(conns float64) {
+		log.Printf("pool member %s: %.f connections\n", member, conns)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("pool member %s drained\n", member)
+
+	if drainForce {
+		log.Printf("forcing pool member %s offline\n", member)
+		err, _ = appliance.OfflinePoolMemberForced(ctx, pool, member)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+}
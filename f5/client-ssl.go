@@ -1,9 +1,9 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -75,12 +75,12 @@ type LBClientSsls struct {
 	Items []LBClientSsl `json:"items"`
 }
 
-func (f *Device) ShowClientSsls() (error, *LBClientSsls) {
+func (f *Device) ShowClientSsls(ctx context.Context, opts ...func(*ListOptions)) (error, *LBClientSsls) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/client-ssl"
 	res := LBClientSsls{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -88,13 +88,13 @@ func (f *Device) ShowClientSsls() (error, *LBClientSsls) {
 	}
 }
 
-func (f *Device) ShowClientSsl(cname string) (error, *LBClientSsl) {
+func (f *Device) ShowClientSsl(ctx context.Context, cname string) (error, *LBClientSsl) {
 
-	client := strings.Replace(cname, "/", "~", -1)
+	client := f.escapeName(cname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/client-ssl/" + client
 	res := LBClientSsl{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -103,12 +103,12 @@ func (f *Device) ShowClientSsl(cname string) (error, *LBClientSsl) {
 
 }
 
-func (f *Device) AddClientSsl(body *json.RawMessage) (error, *LBClientSsl) {
+func (f *Device) AddClientSsl(ctx context.Context, body *json.RawMessage) (error, *LBClientSsl) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/client-ssl"
 	res := LBClientSsl{}
 
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -117,14 +117,14 @@ func (f *Device) AddClientSsl(body *json.RawMessage) (error, *LBClientSsl) {
 
 }
 
-func (f *Device) UpdateClientSsl(cname string, body *json.RawMessage) (error, *LBClientSsl) {
+func (f *Device) UpdateClientSsl(ctx context.Context, cname string, body *json.RawMessage) (error, *LBClientSsl) {
 
-	client := strings.Replace(cname, "/", "~", -1)
+	client := f.escapeName(cname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/client-ssl/" + client
 	res := LBClientSsl{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -133,8 +133,8 @@ func (f *Device) UpdateClientSsl(cname string, body *json.RawMessage) (error, *L
 
 }
 
-func (f *Device) PatchClientSsl(name string, patch *LBClientSsl) (error, *LBClientSsl) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchClientSsl(ctx context.Context, name string, patch *LBClientSsl) (error, *LBClientSsl) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/profile/client-ssl/%s", f.Proto, f.Hostname, name)
 	existing := &LBClientSsl{}
 	var err error
@@ -143,7 +143,7 @@ func (f *Device) PatchClientSsl(name string, patch *LBClientSsl) (error, *LBClie
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowClientSsl(name)
+		err, existing = f.ShowClientSsl(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -166,7 +166,7 @@ func (f *Device) PatchClientSsl(name string, patch *LBClientSsl) (error, *LBClie
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -175,13 +175,13 @@ func (f *Device) PatchClientSsl(name string, patch *LBClientSsl) (error, *LBClie
 		}
 	}
 }
-func (f *Device) DeleteClientSsl(cname string) (error, *Response) {
+func (f *Device) DeleteClientSsl(ctx context.Context, cname string) (error, *Response) {
 
-	client := strings.Replace(cname, "/", "~", -1)
+	client := f.escapeName(cname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/client-ssl/" + client
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -0,0 +1,236 @@
+package f5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the failover and sync status of a device are returned as nested stats of
+// descriptions, eg.
+// {"entries":{"https://localhost/mgmt/tm/cm/sync-status/0":{"nestedStats":{"entries":{"status":{"description":"In Sync"}, ...}}}}}
+type lbStatusEntry struct {
+	Description string         `json:"description"`
+	NestedStats *lbStatusStats `json:"nestedStats"`
+}
+
+type lbStatusStats struct {
+	Entries map[string]lbStatusEntry `json:"entries"`
+}
+
+// fields returns the descriptions of the first entry along with any details
+func (s *lbStatusStats) fields() (map[string]string, []string) {
+
+	fields := make(map[string]string)
+	details := make([]string, 0)
+	for _, top := range s.Entries {
+		if top.NestedStats == nil {
+			continue
+		}
+		for key, entry := range top.NestedStats.Entries {
+			if entry.NestedStats == nil {
+				fields[key] = entry.Description
+				continue
+			}
+			// details are nested another level down, one entry per line
+			keys := make([]string, 0, len(entry.NestedStats.Entries))
+			for k := range entry.NestedStats.Entries {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if d := entry.NestedStats.Entries[k].NestedStats; d != nil {
+					details = append(details, d.Entries["details"].Description)
+				}
+			}
+		}
+		break
+	}
+	return fields, details
+
+}
+
+type LBFailoverStatus struct {
+	Status  string   `json:"status"`
+	Color   string   `json:"color"`
+	Summary string   `json:"summary"`
+	Details []string `json:"details"`
+}
+
+type LBSyncStatus struct {
+	Status  string   `json:"status"`
+	Color   string   `json:"color"`
+	Mode    string   `json:"mode"`
+	Summary string   `json:"summary"`
+	Details []string `json:"details"`
+}
+
+type LBDeviceGroup struct {
+	Name     string `json:"name"`
+	FullPath string `json:"fullPath"`
+	Type     string `json:"type"`
+}
+
+type LBDeviceGroups struct {
+	Items []LBDeviceGroup `json:"items"`
+}
+
+// FailoverStatus shows whether the device is active, standby or offline
+func (f *Device) FailoverStatus(ctx context.Context) (error, *LBFailoverStatus) {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/cm/failover-status"
+	res := lbStatusStats{}
+
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	}
+	fields, details := res.fields()
+	return nil, &LBFailoverStatus{
+		Status:  fields["status"],
+		Color:   fields["color"],
+		Summary: fields["summary"],
+		Details: details,
+	}
+
+}
+
+// IsActive reports whether the device is the active unit. A standalone device
+// is always active.
+func (f *Device) IsActive(ctx context.Context) (error, bool) {
+
+	err, res := f.FailoverStatus(ctx)
+	if err != nil {
+		return err, false
+	}
+	return nil, res.Status == "ACTIVE"
+
+}
+
+// IsStandby reports whether the device says it is a standby unit. Devices
+// that are active, standalone or offline are not.
+func (f *Device) IsStandby(ctx context.Context) (error, bool) {
+
+	err, res := f.FailoverStatus(ctx)
+	if err != nil {
+		return err, false
+	}
+	return nil, res.Status == "STANDBY"
+
+}
+
+// ActivePeer returns the member of the device's cluster that is currently active
+func (f *Device) ActivePeer(ctx context.Context) (error, *LBDeviceState) {
+
+	err, res := f.ShowDevice(ctx)
+	if err != nil {
+		return err, nil
+	}
+	for _, dev := range res.Items {
+		if dev.FailoverState == "active" {
+			return nil, &dev
+		}
+	}
+	return errors.New("error: no active device found in the cluster"), nil
+
+}
+
+func (f *Device) ShowDeviceGroups(ctx context.Context, opts ...func(*ListOptions)) (error, *LBDeviceGroups) {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/cm/device-group"
+	res := LBDeviceGroups{}
+
+	err := f.list(ctx, u, &res, opts)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, &res
+	}
+
+}
+
+// SyncGroup finds the sync-failover device group the device belongs to
+func (f *Device) SyncGroup(ctx context.Context) (error, string) {
+
+	err, res := f.ShowDeviceGroups(ctx)
+	if err != nil {
+		return err, ""
+	}
+	groups := make([]string, 0)
+	for _, g := range res.Items {
+		if g.Type == "sync-failover" {
+			groups = append(groups, g.FullPath)
+		}
+	}
+	switch len(groups) {
+	case 0:
+		return errors.New("error: device is not in a sync-failover device group"), ""
+	case 1:
+		return nil, groups[0]
+	}
+	return fmt.Errorf("error: device is in more than one sync-failover device group: %s", strings.Join(groups, ", ")), ""
+
+}
+
+func (f *Device) SyncStatus(ctx context.Context) (error, *LBSyncStatus) {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/cm/sync-status"
+	res := lbStatusStats{}
+
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	}
+	fields, details := res.fields()
+	return nil, &LBSyncStatus{
+		Status:  fields["status"],
+		Color:   fields["color"],
+		Mode:    fields["mode"],
+		Summary: fields["summary"],
+		Details: details,
+	}
+
+}
+
+// ConfigSync pushes the configuration of the device to the rest of its device group
+func (f *Device) ConfigSync(ctx context.Context, group string) error {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/cm"
+	b := BashCommand{Command: "run", UtilCommandArgs: "config-sync to-group " + group}
+	r := BashCommandResult{}
+
+	err, _ := f.sendRequest(ctx, u, POST, &b, &r)
+	return err
+
+}
+
+// WaitForSync polls the sync status every interval until the device group is
+// in sync or ctx is done. report is called each time the status changes.
+func (f *Device) WaitForSync(ctx context.Context, interval time.Duration, report func(*LBSyncStatus)) (error, *LBSyncStatus) {
+
+	var last *LBSyncStatus
+	for {
+		err, status := f.SyncStatus(ctx)
+		if err != nil {
+			return err, last
+		}
+		if report != nil && (last == nil || last.Status != status.Status || last.Summary != status.Summary) {
+			report(status)
+		}
+		last = status
+		switch status.Status {
+		case "In Sync", "Standalone":
+			return nil, status
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error: gave up waiting for sync, status is %s : %s", status.Status, ctx.Err()), status
+		case <-time.After(interval):
+		}
+	}
+
+}
@@ -0,0 +1,140 @@
+package f5
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// how many stats endpoints are fetched at once unless set with SetStatsWorkers
+const defaultStatsWorkers = 4
+
+// SetStatsWorkers sets how many stats endpoints Stats fetches at once, 0 for
+// the default
+func (f *Device) SetStatsWorkers(n int) {
+	f.statsWorkers = n
+}
+
+// statsJob fetches the statistics of one endpoint, eg. pools
+type statsJob struct {
+	endpoint string
+	get      func(context.Context) (error, []Metric)
+}
+
+type statsResult struct {
+	metrics []Metric
+	err     error
+	start   time.Time
+	end     time.Time
+}
+
+// runStatsJobs runs jobs on a pool of workers and returns their results in
+// the same order
+func (f *Device) runStatsJobs(ctx context.Context, jobs []statsJob) []statsResult {
+
+	workers := f.statsWorkers
+	if workers <= 0 {
+		workers = defaultStatsWorkers
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	results := make([]statsResult, len(jobs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				res := statsResult{start: time.Now()}
+				res.err, res.metrics = jobs[i].get(ctx)
+				res.end = time.Now()
+				results[i] = res
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+
+}
+
+// collectorMetrics reports how long fetching an endpoint took and how many of
+// its requests failed
+func collectorMetrics(endpoint string, took time.Duration, errors int, sampled time.Time) []Metric {
+	return []Metric{
+		newMetric("collector", "", endpoint, "", "durationSeconds", took.Seconds(), sampled),
+		newMetric("collector", "", endpoint, "", "errors", float64(errors), sampled),
+	}
+}
+
+// Stats collects the statistics of every pool, pool member, virtual server,
+// node and iRule and of the system, fetching several endpoints at once. An
+// endpoint that fails doesn't stop the rest. Instead each endpoint gets
+// collector metrics of how long it took and whether it failed, eg.
+// f5.system.collector.pools.errors.
+// An error is only returned if nothing at all could be collected.
+func (f *Device) Stats(ctx context.Context) (error, []Metric) {
+
+	start := time.Now()
+	jobs := []statsJob{
+		{endpoint: "pools", get: f.StatsPools},
+		// the members of every pool in one request
+		{endpoint: "poolmembers", get: f.StatsCommonPoolMembers},
+		{endpoint: "virtuals", get: f.StatsVirtuals},
+		// nodes are shared between pools, so they are fetched once on their own
+		{endpoint: "nodes", get: f.StatsNodes},
+		{endpoint: "rules", get: f.StatsRules},
+		{endpoint: "cpu", get: f.StatsCpu},
+		{endpoint: "memory", get: f.StatsMemory},
+		{endpoint: "disk", get: f.StatsDisk},
+		{endpoint: "throughput", get: f.StatsThroughput},
+		{endpoint: "connections", get: f.StatsConnections},
+	}
+
+	results := f.runStatsJobs(ctx, jobs)
+
+	// the same statistic is only kept once, whichever endpoint it came from
+	seen := make(map[string]bool)
+	data := make([]Metric, 0, 16384)
+	failed := 0
+	var firstErr error
+	for i, res := range results {
+		if res.err != nil {
+			fmt.Fprintf(os.Stderr, "warn: cannot collect %s stats : %s\n", jobs[i].endpoint, res.err)
+			failed++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		for _, m := range res.metrics {
+			if !seen[m.Series()] {
+				seen[m.Series()] = true
+				data = append(data, m)
+			}
+		}
+	}
+	if firstErr != nil && len(data) == 0 {
+		return firstErr, nil
+	}
+
+	sampled := time.Now()
+	for i, res := range results {
+		errors := 0
+		if res.err != nil {
+			errors = 1
+		}
+		data = append(data, collectorMetrics(jobs[i].endpoint, res.end.Sub(res.start), errors, sampled)...)
+	}
+	data = append(data, collectorMetrics("all", sampled.Sub(start), failed, sampled)...)
+	return nil, data
+
+}
@@ -1,5 +1,7 @@
 package f5
 
+import "context"
+
 type BashCommand struct {
 	Command         string `json:"command"`
 	UtilCommandArgs string `json:"utilCmdArgs"`
@@ -12,11 +14,11 @@ type BashCommandResult struct {
 	CommandResult   string `json:"commandResult"`
 }
 
-func (f *Device) Run(command string) (error, *BashCommandResult) {
+func (f *Device) Run(ctx context.Context, command string) (error, *BashCommandResult) {
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/util/bash"
 	b := BashCommand{Command: "run", UtilCommandArgs: "-c \"" + command + "\""}
 	r := BashCommandResult{}
-	err, _ := f.sendRequest(u, POST, &b, &r)
+	err, _ := f.sendRequest(ctx, u, POST, &b, &r)
 	if err != nil {
 		return err, nil
 	}
@@ -1,6 +1,7 @@
 package f5
 
 import (
+	"context"
 	"errors"
 	"strings"
 )
@@ -43,13 +44,13 @@ type SSLCertificates struct {
 	Items    []SSLCertificate `json:"items"`
 }
 
-func (f *Device) GetCertificate(partition string, name string) (error, *SSLCertificate) {
+func (f *Device) GetCertificate(ctx context.Context, partition string, name string) (error, *SSLCertificate) {
 	if !strings.HasSuffix(name, ".crt") {
 		name = name + ".crt"
 	}
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/file/ssl-cert/~" + partition + "~" + name
 	res := SSLCertificate{}
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -57,10 +58,24 @@ func (f *Device) GetCertificate(partition string, name string) (error, *SSLCerti
 	}
 }
 
-func (f *Device) GetCertificates() (error, *SSLCertificates) {
+func (f *Device) GetKey(ctx context.Context, partition string, name string) (error, *SSLCertificate) {
+	if !strings.HasSuffix(name, ".key") {
+		name = name + ".key"
+	}
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/file/ssl-key/~" + partition + "~" + name
+	res := SSLCertificate{}
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, &res
+	}
+}
+
+func (f *Device) GetCertificates(ctx context.Context, opts ...func(*ListOptions)) (error, *SSLCertificates) {
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/file/ssl-cert"
 	res := SSLCertificates{}
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -68,7 +83,7 @@ func (f *Device) GetCertificates() (error, *SSLCertificates) {
 	}
 }
 
-func (f *Device) CreateCertificateFromLocalFile(name string, partition string, cert_file string) (error, *SSLCertificate) {
+func (f *Device) CreateCertificateFromLocalFile(ctx context.Context, name string, partition string, cert_file string) (error, *SSLCertificate) {
 	if !strings.HasSuffix(name, ".crt") {
 		name = name + ".crt"
 	}
@@ -76,7 +91,7 @@ func (f *Device) CreateCertificateFromLocalFile(name string, partition string, c
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/file/ssl-cert"
 	res := SSLCertificate{}
 
-	err, _ := f.sendRequest(u, POST, b, &res)
+	err, _ := f.sendRequest(ctx, u, POST, b, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -85,7 +100,7 @@ func (f *Device) CreateCertificateFromLocalFile(name string, partition string, c
 
 }
 
-func (f *Device) CreateKeyFromLocalFile(name string, partition string, key_file string) (error, *SSLCertificate) {
+func (f *Device) CreateKeyFromLocalFile(ctx context.Context, name string, partition string, key_file string) (error, *SSLCertificate) {
 	if strings.HasSuffix(name, ".crt") {
 		return errors.New("The name cannot contain a .crt suffix for keys."), nil
 	}
@@ -96,7 +111,7 @@ func (f *Device) CreateKeyFromLocalFile(name string, partition string, key_file
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/file/ssl-key"
 	res := SSLCertificate{}
 
-	err, _ := f.sendRequest(u, POST, &b, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &b, &res)
 	if err != nil {
 		return err, nil
 	} else {
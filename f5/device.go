@@ -1,5 +1,7 @@
 package f5
 
+import "context"
+
 type LBDeviceRef struct {
 	Link  string          `json:"selfLink"`
 	Items []LBDeviceState `json:"items"`
@@ -10,14 +12,15 @@ type LBDeviceState struct {
 	Path          string `json:"fullPath"`
 	FailoverState string `json:"failoverState"`
 	ManagementIP  string `json:"managementIP"`
+	SelfDevice    string `json:"selfDevice"`
 }
 
-func (f *Device) ShowDevice() (error, *LBDeviceRef) {
+func (f *Device) ShowDevice(ctx context.Context) (error, *LBDeviceRef) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/cm/device"
 	res := LBDeviceRef{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
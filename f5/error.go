@@ -0,0 +1,110 @@
+package f5
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Error is returned whenever the device does not accept a request
+type Error struct {
+	// http status of the response
+	Status int `json:"status"`
+	// F5 error code taken from the message, eg. 01020036:3
+	Code       string   `json:"code,omitempty"`
+	Message    string   `json:"message"`
+	Method     string   `json:"method"`
+	Url        string   `json:"url"`
+	ErrorStack []string `json:"errorStack,omitempty"`
+	// raw body of the response
+	Body string `json:"body,omitempty"`
+}
+
+// the body of an error response from the device, eg.
+// {"code":404,"message":"01020036:3: The requested Pool (/Common/foo) was not found.","errorStack":[],"apiError":3}
+type httperr struct {
+	Code       int      `json:"code"`
+	Message    string   `json:"message"`
+	ErrorStack []string `json:"errorStack"`
+	ApiError   int      `json:"apiError"`
+}
+
+var errorCode = regexp.MustCompile(`^([0-9a-fA-F]{8}:[0-9]+):`)
+
+// F5 error codes for the common failures, reported with a variety of http statuses
+const (
+	codeNotFound      = "01020036"
+	codeAlreadyExists = "01020066"
+)
+
+func newError(method string, u string, status int, body string, e *httperr) *Error {
+
+	err := &Error{
+		Status: status,
+		Method: method,
+		Url:    u,
+		Body:   body,
+	}
+	if e != nil {
+		err.Message = e.Message
+		err.ErrorStack = e.ErrorStack
+	}
+	if err.Message == "" && body != "" {
+		// not every error comes from iControl REST, eg. a proxy in the way
+		parsed := httperr{}
+		if json.Unmarshal([]byte(body), &parsed) == nil && parsed.Message != "" {
+			err.Message = parsed.Message
+			err.ErrorStack = parsed.ErrorStack
+		}
+	}
+	if m := errorCode.FindStringSubmatch(err.Message); m != nil {
+		err.Code = m[1]
+	}
+	return err
+
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("error: %s %s : %d %s", e.Method, e.Url, e.Status, http.StatusText(e.Status))
+}
+
+// hasCode reports whether the F5 error code starts with code, ignoring the
+// severity after the colon
+func (e *Error) hasCode(code string) bool {
+	return len(e.Code) >= len(code) && e.Code[:len(code)] == code
+}
+
+func asError(err error) (*Error, bool) {
+	var e *Error
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// IsNotFound reports whether err is caused by a missing object
+func IsNotFound(err error) bool {
+	e, ok := asError(err)
+	return ok && (e.Status == http.StatusNotFound || e.hasCode(codeNotFound))
+}
+
+// IsConflict reports whether err is caused by an object that already exists
+func IsConflict(err error) bool {
+	e, ok := asError(err)
+	return ok && (e.Status == http.StatusConflict || e.hasCode(codeAlreadyExists))
+}
+
+// IsValidation reports whether the device rejected the content of a request
+func IsValidation(err error) bool {
+	e, ok := asError(err)
+	return ok && e.Status == http.StatusBadRequest && !e.hasCode(codeNotFound) && !e.hasCode(codeAlreadyExists)
+}
+
+// IsUnauthorized reports whether the device rejected the credentials used
+func IsUnauthorized(err error) bool {
+	e, ok := asError(err)
+	return ok && (e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden)
+}
@@ -1,9 +1,10 @@
 package f5
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,18 +17,6 @@ import (
 	"github.com/rabbitt/f5er/mergo"
 )
 
-var (
-	//sessn   napping.Session
-	tsport            http.Transport
-	clnt              http.Client
-	headers           http.Header
-	debug             bool = false
-	dryrun            bool = false
-	mergeStrategy     mergo.MergeStrategy
-	tokenMutex        = sync.Mutex{}
-	stats_path_prefix string
-)
-
 const (
 	GET = iota
 	POST
@@ -38,25 +27,39 @@ const (
 	DELETE
 )
 
-type httperr struct {
-	Message string
-	Errors  []struct {
-		Resource string
-		Field    string
-		Code     string
-	}
-}
+var methodNames = [...]string{"GET", "POST", "POST", "PUT", "PUT", "PATCH", "DELETE"}
 
 type Device struct {
 	Hostname        string
 	Username        string
 	Password        string
-	Session         napping.Session
 	AuthToken       authToken
 	AuthMethod      AuthMethod
 	Proto           string
 	StatsPathPrefix string
 	StatsShowZeroes bool
+	Partition       string
+
+	// per device connection state - never shared between devices
+	transport     *http.Transport
+	client        *http.Client
+	debug         bool
+	dryrun        bool
+	mergeStrategy mergo.MergeStrategy
+	tokenMutex    sync.Mutex
+
+	// retries and limits
+	retries      int
+	retryWait    time.Duration
+	retryMaxWait time.Duration
+	timeout      time.Duration
+	slots        chan struct{}
+	rateMutex    sync.Mutex
+	rateInterval time.Duration
+	rateNext     time.Time
+
+	// stats endpoints fetched at once
+	statsWorkers int
 }
 
 type Response struct {
@@ -76,6 +79,19 @@ type LBTransactionState struct {
 	State string `json:"state"`
 }
 
+// a single command queued in a transaction
+type LBTransactionCommand struct {
+	CommandId int         `json:"commandId"`
+	EvalOrder int         `json:"evalOrder"`
+	Method    string      `json:"method"`
+	Uri       string      `json:"uri"`
+	Body      interface{} `json:"body,omitempty"`
+}
+
+type LBTransactionCommands struct {
+	Items []LBTransactionCommand `json:"items"`
+}
+
 type AuthMethod int
 
 const (
@@ -102,64 +118,85 @@ func NewInsecure(host string, username string, pwd string, authMethod AuthMethod
 
 func (f *Device) InitSession() {
 	// REST connection setup
+	f.transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
 	if f.Proto == "https" {
-		tsport = http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			Proxy:           http.ProxyFromEnvironment,
-		}
-		clnt = http.Client{Transport: &tsport}
-	} else {
-		clnt = http.Client{}
+		f.transport.TLSClientConfig = &tls.Config{}
 	}
-	headers = make(http.Header)
+	f.client = &http.Client{Transport: f.transport}
+	if f.retryWait == 0 {
+		f.SetRetries(defaultRetries, defaultRetryWait, defaultRetryMaxWait)
+	}
+}
 
-	//
-	// Setup HTTP Basic auth for this session (ONLY use this with SSL).  Auth
-	// can also be configured on a per-request basis when using Send().
-	//
-	f.Session = napping.Session{
-		Client: &clnt,
-		Log:    debug,
+// contextTransport ties every request made through it to a context, so that
+// cancelling the context or reaching its deadline aborts the request
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// session builds a napping session for a single request. Nothing about it is
+// shared, so concurrent requests and transactions can't interfere.
+func (f *Device) session(ctx context.Context, token string) *napping.Session {
+
+	header := make(http.Header)
+	s := napping.Session{
+		Client: &http.Client{Transport: contextTransport{ctx: ctx, base: f.client.Transport}},
+		Log:    f.debug,
+		Header: &header,
+	}
+	if token != "" {
+		header.Set("X-F5-Auth-Token", token)
+	} else {
 		// if Userinfo is set - napping will set the basic auth header for you
-		Userinfo: url.UserPassword(f.Username, f.Password),
-		Header:   &headers,
+		s.Userinfo = url.UserPassword(f.Username, f.Password)
 	}
+	if tid := TransactionId(ctx); tid != "" {
+		header.Set("X-F5-REST-Coordination-Id", tid)
+	}
+	return &s
 
 }
 
 func (f *Device) Debug() bool {
-	return debug
+	return f.debug
 }
 
 func (f *Device) SetDebug(b bool) {
-	debug = b
+	f.debug = b
 }
 
 func (f *Device) DryRun() bool {
-	return dryrun
+	return f.dryrun
 }
 
 func (f *Device) SetDryRun(b bool) {
-	dryrun = b
+	f.dryrun = b
 }
 
 func (f *Device) SetMergeStrategy(s string) {
 	switch s {
 	case "none", "overwrite":
-		mergeStrategy = mergo.Overwrite
+		f.mergeStrategy = mergo.Overwrite
 	case "append", "additive":
-		mergeStrategy = mergo.AppendAdditive
+		f.mergeStrategy = mergo.AppendAdditive
 	case "unique-keep-existing", "unique-from-existing":
-		mergeStrategy = mergo.UniqueLastSeen
+		f.mergeStrategy = mergo.UniqueLastSeen
 	case "unique", "unique-keep-patch", "unique-from-patch":
-		mergeStrategy = mergo.UniqueFirstSeen
+		f.mergeStrategy = mergo.UniqueFirstSeen
 	default:
 		panic(fmt.Sprintf("Invalid merge-strategy '%s'", s))
 	}
 }
 
 func (f *Device) MergeStrategy() mergo.MergeStrategy {
-	return mergeStrategy
+	return f.mergeStrategy
 }
 
 func (f *Device) MergeConfig() func(*mergo.Config) {
@@ -182,7 +219,7 @@ func (f *Device) SetTokenAuth(t bool) {
 		f.AuthMethod = BASIC_AUTH
 		debugout = "BASIC_AUTH"
 	}
-	if debug {
+	if f.debug {
 		fmt.Printf("authentication mode: %s\n", debugout)
 	}
 }
@@ -199,32 +236,60 @@ func (f *Device) SetStatsShowZeroes(b bool) {
 	f.StatsShowZeroes = b
 }
 
-func (f *Device) StartTransaction() (error, string) {
+// SetPartition sets the partition used for object names given without one
+func (f *Device) SetPartition(p string) {
+	f.Partition = strings.Trim(p, "/")
+}
+
+// escapeName turns an object name into the form used in a url,
+// ie. /Common/webserver01 -> ~Common~webserver01
+func (f *Device) escapeName(name string) string {
+	if f.Partition != "" && name != "" && !strings.ContainsAny(name, "/~") {
+		name = "/" + f.Partition + "/" + name
+	}
+	return strings.Replace(name, "/", "~", -1)
+}
+
+type transactionKey struct{}
+
+// WithTransaction returns a copy of ctx that queues every request made with it
+// in the transaction tid instead of running it straight away
+func WithTransaction(ctx context.Context, tid string) context.Context {
+	return context.WithValue(ctx, transactionKey{}, tid)
+}
+
+// TransactionId returns the transaction requests made with ctx are queued in,
+// or an empty string if there is none
+func TransactionId(ctx context.Context) string {
+	tid, _ := ctx.Value(transactionKey{}).(string)
+	return tid
+}
+
+// StartTransaction creates a new transaction. Requests are only added to it
+// when made with a context from WithTransaction.
+func (f *Device) StartTransaction(ctx context.Context) (error, string) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/transaction"
 	empty := LBEmptyBody{}
 	tres := LBTransaction{}
-	err, _ := f.sendRequest(u, POST, &empty, &tres)
+	err, _ := f.sendRequest(ctx, u, POST, &empty, &tres)
 	if err != nil {
 		return err, ""
 	}
 
-	tid := fmt.Sprintf("%d", tres.TransId)
-	// set the transaction header
-	f.Session.Header.Set("X-F5-REST-Coordination-Id", tid)
-	return nil, tid
+	return nil, fmt.Sprintf("%d", tres.TransId)
 
 }
 
-func (f *Device) CommitTransaction(tid string) error {
+func (f *Device) CommitTransaction(ctx context.Context, tid string) error {
 
-	// remove the transaction header first
-	f.Session.Header.Del("X-F5-REST-Coordination-Id")
+	// the commit itself must not be queued in the transaction
+	ctx = WithTransaction(ctx, "")
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/transaction/" + tid
 	body := LBTransaction{State: "VALIDATING"}
 	tres := LBTransaction{}
-	err, _ := f.sendRequest(u, PATCH, &body, &tres)
+	err, _ := f.sendRequest(ctx, u, PATCH, &body, &tres)
 	if err != nil {
 		return err
 	}
@@ -233,33 +298,115 @@ func (f *Device) CommitTransaction(tid string) error {
 
 }
 
-func (f *Device) sendRequest(u string, method int, pload interface{}, res interface{}) (error, *Response) {
+// DeleteTransaction aborts a transaction, discarding any commands queued in it
+func (f *Device) DeleteTransaction(ctx context.Context, tid string) error {
+
+	ctx = WithTransaction(ctx, "")
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/transaction/" + tid
+	res := json.RawMessage{}
+	err, _ := f.sendRequest(ctx, u, DELETE, nil, &res)
+	if err != nil {
+		return err
+	}
+
+	return nil
+
+}
+
+// ShowTransactionCommands lists the commands queued in a transaction in the
+// order they will be evaluated
+func (f *Device) ShowTransactionCommands(ctx context.Context, tid string) (error, *LBTransactionCommands) {
+
+	ctx = WithTransaction(ctx, "")
 
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/transaction/" + tid + "/commands"
+	res := LBTransactionCommands{}
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	}
+
+	return nil, &res
+
+}
+
+func (f *Device) sendRequest(ctx context.Context, u string, method int, pload interface{}, res interface{}) (error, *Response) {
+
+	var token string
 	if f.AuthMethod == TOKEN {
-		f.ensureValidToken()
+		var err error
+		if err, token = f.ensureValidToken(ctx); err != nil {
+			return err, nil
+		}
+	}
+
+	// a raw payload is read as it is sent, so keep a copy for any retries
+	var raw []byte
+	if b, ok := pload.(*bytes.Buffer); ok {
+		raw = b.Bytes()
+	}
+
+	attempts := 1
+	if idempotent(method) {
+		attempts += f.retries
+	}
+	for attempt := 0; ; attempt++ {
+
+		if raw != nil {
+			pload = bytes.NewBuffer(raw)
+		}
+		err, resp := f.attempt(ctx, token, u, method, pload, res)
+		if err == nil || attempt+1 >= attempts || ctx.Err() != nil || !isTransient(err) {
+			return err, resp
+		}
+
+		wait := f.backoff(attempt)
+		if f.debug {
+			log.Printf("%s %s failed: %s - retrying in %s\n", methodNames[method], u, err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err, resp
+		}
+	}
+
+}
+
+// attempt sends a request once
+func (f *Device) attempt(ctx context.Context, token string, u string, method int, pload interface{}, res interface{}) (error, *Response) {
+
+	err, release := f.acquire(ctx)
+	if err != nil {
+		return err, nil
+	}
+	defer release()
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
 	}
 
 	//
 	// Send request to server
 	//
 	e := httperr{}
-	var (
-		err   error
-		nresp *napping.Response
-	)
-	f.Session.Log = debug
+	var nresp *napping.Response
+	sess := f.session(ctx, token)
 
 	switch method {
 	case GET:
-		nresp, err = f.Session.Get(u, nil, &res, &e)
+		nresp, err = sess.Get(u, nil, &res, &e)
 	case POST:
-		nresp, err = f.Session.Post(u, &pload, &res, &e)
+		nresp, err = sess.Post(u, &pload, &res, &e)
 	case PUT:
-		nresp, err = f.Session.Put(u, &pload, &res, &e)
+		nresp, err = sess.Put(u, &pload, &res, &e)
 	case PATCH:
-		nresp, err = f.Session.Patch(u, &pload, &res, &e)
+		nresp, err = sess.Patch(u, &pload, &res, &e)
 	case DELETE:
-		nresp, err = f.Session.Delete(u, nil, &res, &e)
+		nresp, err = sess.Delete(u, nil, &res, &e)
 	case POSTR:
 		r := napping.Request{
 			Method:     "POST",
@@ -268,9 +415,9 @@ func (f *Device) sendRequest(u string, method int, pload interface{}, res interf
 			Payload:    pload,
 			RawPayload: true,
 			Result:     res,
-			Error:      e,
+			Error:      &e,
 		}
-		nresp, err = f.Session.Send(&r)
+		nresp, err = sess.Send(&r)
 	case PUTR:
 		r := napping.Request{
 			Method:     "PUT",
@@ -281,7 +428,7 @@ func (f *Device) sendRequest(u string, method int, pload interface{}, res interf
 			Result:     &res,
 			Error:      &e,
 		}
-		nresp, err = f.Session.Send(&r)
+		nresp, err = sess.Send(&r)
 	}
 
 	var resp Response
@@ -289,19 +436,19 @@ func (f *Device) sendRequest(u string, method int, pload interface{}, res interf
 		resp = Response{Status: nresp.Status(), Message: e.Message}
 	}
 
+	// an error body that isn't json also shows up as err, so check the status first
+	if nresp != nil && nresp.Status() >= 300 {
+		ferr := newError(methodNames[method], u, nresp.Status(), nresp.RawText(), &e)
+		if nresp.Status() == 401 {
+			ferr.Message = "error: 401 Unauthorised - check your username and passwd"
+		}
+		return ferr, &resp
+	}
 	if err != nil {
 		return err, &resp
 	}
-	if nresp.Status() == 401 {
-		f.PrintObject(resp)
-		return errors.New("error: 401 Unauthorised - check your username and passwd"), &resp
-	}
-	if nresp.Status() >= 300 {
-		return errors.New(e.Message), &resp
-	} else {
-		// all is good in the world
-		return nil, &resp
-	}
+	// all is good in the world
+	return nil, &resp
 }
 
 func PrintObject(input interface{}) {
@@ -330,12 +477,12 @@ type LBModules struct {
 	Items []LBModuleRef `json:"items"`
 }
 
-func (f *Device) ShowModules() (error, *LBModules) {
+func (f *Device) ShowModules(ctx context.Context) (error, *LBModules) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm"
 	res := LBModules{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -343,7 +490,14 @@ func (f *Device) ShowModules() (error, *LBModules) {
 	}
 }
 
-func (f *Device) GetToken() {
+// GetToken logs in to the device and keeps the token for later requests
+func (f *Device) GetToken(ctx context.Context) error {
+	f.tokenMutex.Lock()
+	defer f.tokenMutex.Unlock()
+	return f.login(ctx)
+}
+
+func (f *Device) login(ctx context.Context) error {
 
 	type login struct {
 		Token struct {
@@ -354,28 +508,30 @@ func (f *Device) GetToken() {
 
 	LoginData := map[string]string{"username": f.Username, "password": f.Password, "loginProviderName": "tmos"}
 	byteLogin, err := json.Marshal(LoginData)
+	if err != nil {
+		return err
+	}
 	body := json.RawMessage(byteLogin)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/shared/authn/login"
 	res := login{}
 	e := httperr{}
 
-	resp, err := f.Session.Post(u, &body, &res, &e)
-	if err != nil {
-		log.Fatal(fmt.Errorf("error: %s, %v", err, resp))
-		return
+	resp, err := f.session(ctx, "").Post(u, &body, &res, &e)
+	if err != nil && (resp == nil || resp.Status() < 300) {
+		return fmt.Errorf("error: %s, %v", err, resp)
 	}
-	if debug {
+	if f.debug {
 		f.PrintObject(&resp)
 	}
+	if resp.Status() >= 300 {
+		return newError("POST", u, resp.Status(), resp.RawText(), &e)
+	}
 
 	f.AuthToken = authToken{
 		Token:            res.Token.Token,
 		ExpirationMicros: res.Token.ExpirationMicros,
 	}
-	f.Session.Header.Set("X-F5-Auth-Token", f.AuthToken.Token)
-
-	// disable basic auth now
-	f.Session.Userinfo = nil
+	return nil
 }
 
 func (f *Device) hasValidToken() bool {
@@ -386,10 +542,15 @@ func (f *Device) hasValidToken() bool {
 	return true
 }
 
-func (f *Device) ensureValidToken() {
-	tokenMutex.Lock()
-	defer tokenMutex.Unlock()
+// ensureValidToken logs in again if the token is missing or about to expire
+// and returns the token to use for a request
+func (f *Device) ensureValidToken(ctx context.Context) (error, string) {
+	f.tokenMutex.Lock()
+	defer f.tokenMutex.Unlock()
 	if !f.hasValidToken() {
-		f.GetToken()
+		if err := f.login(ctx); err != nil {
+			return err, ""
+		}
 	}
+	return nil, f.AuthToken.Token
 }
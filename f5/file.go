@@ -2,44 +2,69 @@ package f5
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"errors"
 	"net/http"
 	"strconv"
 )
 
-func (f *Device) UploadFile(filename string, data []byte) error {
+func (f *Device) UploadFile(ctx context.Context, filename string, data []byte) error {
 	if len(data) > 512*1024 {
 		return errors.New("File size is too large, and we dont support chunked file sizes yet.")
 	}
 
+	// the upload gives up its slot before the chmod takes one
+	if err := f.upload(ctx, filename, data); err != nil {
+		return err
+	}
+
+	err, _ := f.Run(ctx, "chmod 644 /var/config/rest/downloads/"+filename)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// upload posts the file, sharing the limits on concurrent requests, rate and
+// time of every other request to the device
+func (f *Device) upload(ctx context.Context, filename string, data []byte) error {
+
+	err, release := f.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
 	var url string = f.Proto + "://" + f.Hostname + "/mgmt/shared/file-transfer/uploads/" + filename
 	request, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
 	if err != nil {
 		return err
 	}
+	request = request.WithContext(ctx)
 	request.SetBasicAuth(f.Username, f.Password)
 	request.Header.Set("Content-Type", "application/octet-stream")
 	request.Header.Set("Content-Range", "0-"+strconv.Itoa(len(data)-1)+"/"+strconv.Itoa(len(data)))
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	response, err := client.Do(request)
+	response, err := f.client.Do(request)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 
 	if response.StatusCode > 299 || response.StatusCode < 200 {
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(response.Body)
-		s := buf.String()
-		return errors.New("Unable to process request, returned status: " + response.Status + " " + s)
-	}
-
-	err, _ = f.Run("chmod 644 /var/config/rest/downloads/" + filename)
-	if err != nil {
-		return err
+		ferr := newError("POST", url, response.StatusCode, buf.String(), nil)
+		if ferr.Message == "" {
+			ferr.Message = "Unable to process request, returned status: " + response.Status + " " + ferr.Body
+		}
+		return ferr
 	}
 	return nil
+
 }
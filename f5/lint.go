@@ -0,0 +1,735 @@
+package f5
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type LintSeverity int
+
+const (
+	// something that will probably work, but not as intended or not well
+	LintWarning LintSeverity = iota
+	// something the device will reject or that will fail every time
+	LintError
+)
+
+func (s LintSeverity) String() string {
+	if s == LintError {
+		return "error"
+	}
+	return "warning"
+}
+
+// LintProblem is something wrong found in the TCL of an iRule
+type LintProblem struct {
+	Line     int
+	Severity LintSeverity
+	Message  string
+}
+
+func (p LintProblem) String() string {
+	return fmt.Sprintf("%d: %s: %s", p.Line, p.Severity, p.Message)
+}
+
+// iRuleEvents are the events an iRule can handle with when
+var iRuleEvents = map[string]bool{
+	"RULE_INIT": true,
+
+	"CLIENT_ACCEPTED":  true,
+	"CLIENT_CLOSED":    true,
+	"CLIENT_DATA":      true,
+	"SERVER_CONNECTED": true,
+	"SERVER_CLOSED":    true,
+	"SERVER_DATA":      true,
+	"SERVER_INIT":      true,
+	"USER_REQUEST":     true,
+	"USER_RESPONSE":    true,
+	"FLOW_INIT":        true,
+
+	"CLIENTSSL_HANDSHAKE":        true,
+	"CLIENTSSL_CLIENTHELLO":      true,
+	"CLIENTSSL_SERVERHELLO_SEND": true,
+	"CLIENTSSL_CLIENTCERT":       true,
+	"CLIENTSSL_DATA":             true,
+	"CLIENTSSL_PASSTHROUGH":      true,
+	"SERVERSSL_HANDSHAKE":        true,
+	"SERVERSSL_CLIENTHELLO_SEND": true,
+	"SERVERSSL_SERVERHELLO":      true,
+	"SERVERSSL_SERVERCERT":       true,
+	"SERVERSSL_DATA":             true,
+
+	"HTTP_REQUEST":           true,
+	"HTTP_REQUEST_DATA":      true,
+	"HTTP_REQUEST_SEND":      true,
+	"HTTP_REQUEST_RELEASE":   true,
+	"HTTP_RESPONSE":          true,
+	"HTTP_RESPONSE_DATA":     true,
+	"HTTP_RESPONSE_CONTINUE": true,
+	"HTTP_RESPONSE_RELEASE":  true,
+	"HTTP_CLASS_FAILED":      true,
+	"HTTP_CLASS_SELECTED":    true,
+	"HTTP_DISABLED":          true,
+	"HTTP_REJECT":            true,
+	"HTTP_PROXY_REQUEST":     true,
+	"HTTP_PROXY_CONNECT":     true,
+	"HTTP_PROXY_RESPONSE":    true,
+
+	"LB_SELECTED":  true,
+	"LB_FAILED":    true,
+	"LB_QUEUED":    true,
+	"PERSIST_DOWN": true,
+
+	"CACHE_REQUEST":  true,
+	"CACHE_RESPONSE": true,
+	"CACHE_UPDATE":   true,
+
+	"ASM_REQUEST_DONE":       true,
+	"ASM_REQUEST_VIOLATION":  true,
+	"ASM_RESPONSE_VIOLATION": true,
+	"ASM_REQUEST_BLOCKING":   true,
+	"ASM_RESPONSE_LOGIN":     true,
+
+	"ACCESS_SESSION_STARTED":         true,
+	"ACCESS_SESSION_CLOSED":          true,
+	"ACCESS_POLICY_AGENT_EVENT":      true,
+	"ACCESS_POLICY_COMPLETED":        true,
+	"ACCESS_ACL_ALLOWED":             true,
+	"ACCESS_ACL_DENIED":              true,
+	"ACCESS_PER_REQUEST_AGENT_EVENT": true,
+
+	"AUTH_RESULT":         true,
+	"AUTH_WANTCREDENTIAL": true,
+	"AUTH_SUCCESS":        true,
+	"AUTH_FAILURE":        true,
+	"AUTH_ERROR":          true,
+
+	"DNS_REQUEST":   true,
+	"DNS_RESPONSE":  true,
+	"NAME_RESOLVED": true,
+
+	"STREAM_MATCHED":        true,
+	"REWRITE_REQUEST_DONE":  true,
+	"REWRITE_RESPONSE_DONE": true,
+	"HTML_TAG_MATCHED":      true,
+	"HTML_COMMENT_MATCHED":  true,
+
+	"WS_REQUEST":           true,
+	"WS_RESPONSE":          true,
+	"WS_CLIENT_FRAME":      true,
+	"WS_SERVER_FRAME":      true,
+	"WS_CLIENT_DATA":       true,
+	"WS_SERVER_DATA":       true,
+	"WS_CLIENT_FRAME_DONE": true,
+	"WS_SERVER_FRAME_DONE": true,
+
+	"ADAPT_REQUEST_RESULT":   true,
+	"ADAPT_RESPONSE_RESULT":  true,
+	"ADAPT_REQUEST_HEADERS":  true,
+	"ADAPT_RESPONSE_HEADERS": true,
+
+	"SIP_REQUEST":       true,
+	"SIP_RESPONSE":      true,
+	"SIP_REQUEST_SEND":  true,
+	"SIP_RESPONSE_SEND": true,
+	"SIP_REQUEST_DONE":  true,
+	"SIP_RESPONSE_DONE": true,
+
+	"XML_CONTENT_BASED_ROUTING": true,
+	"XML_BEGIN_DOCUMENT":        true,
+	"XML_END_DOCUMENT":          true,
+	"XML_BEGIN_ELEMENT":         true,
+	"XML_END_ELEMENT":           true,
+	"XML_CDATA":                 true,
+
+	"MQTT_CLIENT_INGRESS":    true,
+	"MQTT_CLIENT_EGRESS":     true,
+	"MQTT_SERVER_INGRESS":    true,
+	"MQTT_SERVER_EGRESS":     true,
+	"GENERICMESSAGE_INGRESS": true,
+	"GENERICMESSAGE_EGRESS":  true,
+	"MR_INGRESS":             true,
+	"MR_EGRESS":              true,
+	"MR_FAILED":              true,
+	"DIAMETER_INGRESS":       true,
+	"DIAMETER_EGRESS":        true,
+	"RTSP_REQUEST":           true,
+	"RTSP_RESPONSE":          true,
+
+	"CLASSIFICATION_DETECTED": true,
+	"IN_DOSL7_ATTACK":         true,
+	"BOTDEFENSE_REQUEST":      true,
+	"BOTDEFENSE_ACTION":       true,
+	"QOE_PARSE_DONE":          true,
+	"PCP_REQUEST":             true,
+	"PCP_RESPONSE":            true,
+	"PING_REQUEST_READY":      true,
+	"PING_RESPONSE_READY":     true,
+	"FIX_MESSAGE":             true,
+}
+
+// events where there is no HTTP request or response to use HTTP:: commands on
+var nonHttpEventPrefixes = []string{"RULE_INIT", "CLIENT_", "SERVER_", "CLIENTSSL_", "SERVERSSL_", "DNS_", "SIP_", "DIAMETER_", "MQTT_", "FLOW_INIT"}
+
+// HTTP:: commands that control HTTP processing of the connection, so are
+// used before there is a request, eg. HTTP::disable in CLIENT_ACCEPTED
+var httpConnectionCommands = map[string]bool{
+	"HTTP::disable": true,
+	"HTTP::enable":  true,
+	"HTTP::collect": true,
+	"HTTP::release": true,
+}
+
+// deprecatedCommands are v4 and v9 commands with their replacements
+var deprecatedCommands = map[string]string{
+	"matchclass":   "class match",
+	"findclass":    "class search or class lookup",
+	"http_uri":     "HTTP::uri",
+	"http_host":    "HTTP::host",
+	"http_method":  "HTTP::method",
+	"http_header":  "HTTP::header",
+	"http_cookie":  "HTTP::cookie",
+	"http_version": "HTTP::version",
+	"client_addr":  "IP::client_addr",
+	"server_addr":  "IP::server_addr",
+	"remote_addr":  "IP::remote_addr",
+	"local_addr":   "IP::local_addr",
+	"client_port":  "TCP::client_port",
+	"server_port":  "TCP::server_port",
+	"ip_protocol":  "IP::protocol",
+	"ip_tos":       "IP::tos",
+	"ip_ttl":       "IP::ttl",
+	"link_qos":     "LINK::qos",
+	"use":          "pool",
+	"imid":         "a User-Agent check with HTTP::header",
+}
+
+// commands that can be used outside of an event
+var topLevelCommands = map[string]bool{
+	"when":     true,
+	"proc":     true,
+	"priority": true,
+	"timing":   true,
+	"nodelete": true,
+}
+
+// tclWord is a word of a TCL command, without its braces or quotes
+type tclWord struct {
+	text   string
+	braced bool
+	quoted bool
+	line   int
+	// the line text starts on, after any brace or quote
+	textLine int
+	// commands substituted into the word with [...]
+	subs [][]tclCommand
+}
+
+type tclCommand struct {
+	words []tclWord
+	line  int
+}
+
+// tclParser splits a TCL script into commands and words, as the TCL parser
+// does, stopping at the first unbalanced brace, quote or bracket
+type tclParser struct {
+	src  string
+	pos  int
+	line int
+	// set once the script can't be parsed any further
+	failed   bool
+	problems []LintProblem
+}
+
+func (p *tclParser) fail(line int, format string, args ...interface{}) {
+	p.problems = append(p.problems, LintProblem{line, LintError, fmt.Sprintf(format, args...)})
+	p.failed = true
+}
+
+func (p *tclParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tclParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// escape skips a backslash and the character after it
+func (p *tclParser) escape() {
+	p.next()
+	if !p.eof() {
+		p.next()
+	}
+}
+
+// ends reports whether c ends a word, given the bracket the script is inside
+func ends(c byte, close byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ';' || (close != 0 && c == close)
+}
+
+// script parses commands until the end, or until close outside of any word
+func (p *tclParser) script(close byte) []tclCommand {
+
+	cmds := make([]tclCommand, 0)
+	for !p.failed {
+		for !p.eof() && (ends(p.src[p.pos], 0) || p.src[p.pos] == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n') {
+			p.next()
+		}
+		if p.eof() || (close != 0 && p.src[p.pos] == close) {
+			return cmds
+		}
+		if p.src[p.pos] == '#' {
+			for !p.eof() && p.src[p.pos] != '\n' {
+				if p.src[p.pos] == '\\' {
+					p.escape()
+				} else {
+					p.next()
+				}
+			}
+			continue
+		}
+		if cmd := p.command(close); len(cmd.words) > 0 {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+
+}
+
+func (p *tclParser) command(close byte) tclCommand {
+
+	cmd := tclCommand{line: p.line}
+	for !p.failed {
+		for !p.eof() {
+			c := p.src[p.pos]
+			if c == ' ' || c == '\t' || c == '\r' {
+				p.next()
+			} else if c == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n' {
+				p.escape()
+			} else {
+				break
+			}
+		}
+		if p.eof() || ends(p.src[p.pos], close) {
+			return cmd
+		}
+		if p.src[p.pos] == '}' {
+			p.fail(p.line, "unexpected close-brace")
+			return cmd
+		}
+		cmd.words = append(cmd.words, p.word(close))
+	}
+	return cmd
+
+}
+
+func (p *tclParser) word(close byte) tclWord {
+
+	w := tclWord{line: p.line}
+	switch p.src[p.pos] {
+	case '{':
+		p.next()
+		w.braced = true
+		w.textLine = p.line
+		start := p.pos
+		depth := 1
+		for depth > 0 {
+			if p.eof() {
+				p.fail(w.line, "missing close-brace for the brace opened here")
+				return w
+			}
+			switch p.src[p.pos] {
+			case '\\':
+				p.escape()
+				continue
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			p.next()
+		}
+		w.text = p.src[start : p.pos-1]
+		if !p.eof() && !ends(p.src[p.pos], close) {
+			p.fail(p.line, "extra characters after close-brace")
+		}
+	case '"':
+		p.next()
+		w.quoted = true
+		w.textLine = p.line
+		start := p.pos
+		for {
+			if p.eof() {
+				p.fail(w.line, "missing close-quote for the quote opened here")
+				return w
+			}
+			c := p.src[p.pos]
+			if c == '"' {
+				break
+			}
+			switch c {
+			case '\\':
+				p.escape()
+			case '[':
+				w.subs = append(w.subs, p.substitution())
+				if p.failed {
+					return w
+				}
+			default:
+				p.next()
+			}
+		}
+		w.text = p.src[start:p.pos]
+		p.next()
+		if !p.eof() && !ends(p.src[p.pos], close) {
+			p.fail(p.line, "extra characters after close-quote")
+		}
+	default:
+		w.textLine = p.line
+		start := p.pos
+		for !p.eof() && !ends(p.src[p.pos], close) {
+			switch p.src[p.pos] {
+			case '\\':
+				p.escape()
+			case '[':
+				w.subs = append(w.subs, p.substitution())
+				if p.failed {
+					return w
+				}
+			default:
+				p.next()
+			}
+		}
+		w.text = p.src[start:p.pos]
+	}
+	return w
+
+}
+
+// substitution parses the commands in [...]
+func (p *tclParser) substitution() []tclCommand {
+	line := p.line
+	p.next()
+	cmds := p.script(']')
+	if p.failed {
+		return cmds
+	}
+	if p.eof() {
+		p.fail(line, "missing close-bracket for the bracket opened here")
+		return cmds
+	}
+	p.next()
+	return cmds
+}
+
+func parseTcl(src string, line int) ([]tclCommand, []LintProblem) {
+	p := &tclParser{src: src, line: line}
+	cmds := p.script(0)
+	return cmds, p.problems
+}
+
+// ruleLinter checks the commands of an iRule
+type ruleLinter struct {
+	problems []LintProblem
+	// events seen so far, with their priority
+	events map[string]int
+}
+
+func (l *ruleLinter) add(line int, severity LintSeverity, format string, args ...interface{}) {
+	l.problems = append(l.problems, LintProblem{line, severity, fmt.Sprintf(format, args...)})
+}
+
+// body lints a braced word as a script, in the given event
+func (l *ruleLinter) body(w tclWord, event string) {
+	if !w.braced {
+		// a body in quotes or a variable can't be checked
+		return
+	}
+	cmds, problems := parseTcl(w.text, w.textLine)
+	l.problems = append(l.problems, problems...)
+	l.script(cmds, event)
+}
+
+func (l *ruleLinter) script(cmds []tclCommand, event string) {
+	for _, cmd := range cmds {
+		l.command(cmd, event)
+	}
+}
+
+// expression warns about a condition or expression that isn't braced, which
+// is evaluated twice and is slower
+func (l *ruleLinter) expression(w tclWord, command string, event string) {
+	if !w.braced {
+		l.add(w.line, LintWarning, "unbraced expression in %s - put it in braces", command)
+		return
+	}
+	// the commands in a braced expression are run by expr
+	p := &tclParser{src: w.text, line: w.textLine}
+	for !p.eof() && !p.failed {
+		switch p.src[p.pos] {
+		case '\\':
+			p.escape()
+		case '[':
+			l.script(p.substitution(), event)
+		default:
+			p.next()
+		}
+	}
+	l.problems = append(l.problems, p.problems...)
+}
+
+func isHttpEvent(event string) bool {
+	for _, prefix := range nonHttpEventPrefixes {
+		if strings.HasPrefix(event, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *ruleLinter) command(cmd tclCommand, event string) {
+
+	for _, w := range cmd.words {
+		for _, sub := range w.subs {
+			l.script(sub, event)
+		}
+		if !w.braced && strings.Contains(w.text, "$::") {
+			l.add(w.line, LintWarning, "global variable in \"%s\" - globals stop the rule running on every core, use static:: or table", w.text)
+		}
+	}
+
+	words := cmd.words
+	name := words[0].text
+	args := words[1:]
+
+	if event == "" && !topLevelCommands[name] {
+		l.add(cmd.line, LintError, "%s outside of an event - only when, proc, priority and timing can be used here", name)
+		return
+	}
+	if replacement, ok := deprecatedCommands[name]; ok {
+		l.add(cmd.line, LintWarning, "%s is deprecated - use %s", name, replacement)
+	}
+	if strings.HasPrefix(name, "HTTP::") && !httpConnectionCommands[name] && event != "proc" && !isHttpEvent(event) {
+		l.add(cmd.line, LintError, "%s can't be used in %s, which has no HTTP request or response", name, event)
+	}
+
+	switch name {
+	case "when":
+		l.when(cmd, event)
+	case "proc":
+		if len(args) != 3 {
+			l.add(cmd.line, LintError, "proc needs a name, arguments and a body")
+			return
+		}
+		l.body(args[2], "proc")
+	case "set", "append", "lappend", "incr", "unset":
+		if len(args) > 0 {
+			variable := args[0].text
+			if strings.HasPrefix(variable, "::") {
+				l.add(cmd.line, LintWarning, "global variable %s - globals stop the rule running on every core, use static:: or table", variable)
+			} else if event == "RULE_INIT" && name != "unset" && !strings.HasPrefix(variable, "static::") {
+				l.add(cmd.line, LintWarning, "%s is lost once RULE_INIT is done - use static::%s", variable, variable)
+			}
+		}
+	case "if":
+		l.ifCommand(cmd, event)
+	case "while":
+		if len(args) != 2 {
+			l.add(cmd.line, LintError, "while needs a condition and a body")
+			return
+		}
+		l.expression(args[0], "while", event)
+		l.body(args[1], event)
+	case "for":
+		if len(args) != 4 {
+			l.add(cmd.line, LintError, "for needs a start, condition, next and body")
+			return
+		}
+		l.body(args[0], event)
+		l.expression(args[1], "for", event)
+		l.body(args[2], event)
+		l.body(args[3], event)
+	case "foreach":
+		if len(args) < 3 || len(args)%2 == 0 {
+			l.add(cmd.line, LintError, "foreach needs variables, a list and a body")
+			return
+		}
+		l.body(args[len(args)-1], event)
+	case "catch":
+		if len(args) > 0 {
+			l.body(args[0], event)
+		}
+	case "expr":
+		if len(args) != 1 {
+			l.add(cmd.line, LintWarning, "unbraced expression in expr - put it in braces")
+			return
+		}
+		l.expression(args[0], "expr", event)
+	case "switch":
+		l.switchCommand(cmd, event)
+	}
+
+}
+
+// when EVENT ?priority N? ?timing on|off? body
+func (l *ruleLinter) when(cmd tclCommand, event string) {
+
+	if event != "" {
+		l.add(cmd.line, LintError, "when inside %s - events can't be nested", event)
+		return
+	}
+	args := cmd.words[1:]
+	if len(args) < 2 {
+		l.add(cmd.line, LintError, "when needs an event and a body")
+		return
+	}
+
+	name := args[0].text
+	if !iRuleEvents[name] {
+		l.add(cmd.line, LintError, "unknown event %s", name)
+	}
+	priority := 500
+	for i := 1; i+1 < len(args)-1; i += 2 {
+		switch args[i].text {
+		case "priority":
+			fmt.Sscanf(args[i+1].text, "%d", &priority)
+		case "timing":
+		default:
+			l.add(cmd.line, LintError, "unexpected %s in when %s", args[i].text, name)
+		}
+	}
+	if last, ok := l.events[name]; ok && last == priority {
+		l.add(cmd.line, LintError, "event %s is handled more than once with priority %d", name, priority)
+	}
+	l.events[name] = priority
+
+	body := args[len(args)-1]
+	if !body.braced {
+		l.add(body.line, LintError, "the body of when %s must be in braces", name)
+		return
+	}
+	l.body(body, name)
+
+}
+
+// if cond ?then? body ?elseif cond ?then? body ...? ?else? ?body?
+func (l *ruleLinter) ifCommand(cmd tclCommand, event string) {
+
+	args := cmd.words[1:]
+	for i := 0; i < len(args); {
+		l.expression(args[i], "if", event)
+		i++
+		if i < len(args) && args[i].text == "then" && !args[i].braced {
+			i++
+		}
+		if i >= len(args) {
+			l.add(cmd.line, LintError, "if has a condition with no body")
+			return
+		}
+		l.body(args[i], event)
+		i++
+		if i >= len(args) {
+			return
+		}
+		switch args[i].text {
+		case "elseif":
+			i++
+		case "else":
+			if i+1 >= len(args) {
+				l.add(cmd.line, LintError, "else has no body")
+				return
+			}
+			l.body(args[i+1], event)
+			if i+2 < len(args) {
+				l.add(cmd.line, LintError, "extra words after else")
+			}
+			return
+		default:
+			l.add(args[i].line, LintError, "expected elseif or else but found %s", args[i].text)
+			return
+		}
+		if i >= len(args) {
+			l.add(cmd.line, LintError, "elseif has no condition")
+			return
+		}
+	}
+
+}
+
+// switch ?options? string {pattern body ...} or switch ?options? string pattern body ...
+func (l *ruleLinter) switchCommand(cmd tclCommand, event string) {
+
+	args := cmd.words[1:]
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i].text, "-") && !args[i].braced {
+		i++
+		if args[i-1].text == "--" {
+			break
+		}
+	}
+	// the string switched on
+	i++
+	if i >= len(args) {
+		l.add(cmd.line, LintError, "switch needs a string and patterns")
+		return
+	}
+
+	var pairs []tclWord
+	if i == len(args)-1 {
+		if !args[i].braced {
+			return
+		}
+		cmds, problems := parseTcl(args[i].text, args[i].textLine)
+		l.problems = append(l.problems, problems...)
+		for _, c := range cmds {
+			pairs = append(pairs, c.words...)
+		}
+	} else {
+		pairs = args[i:]
+	}
+	if len(pairs)%2 != 0 {
+		l.add(cmd.line, LintError, "switch has a pattern with no body")
+		return
+	}
+	for j := 1; j < len(pairs); j += 2 {
+		if pairs[j].text != "-" || pairs[j].braced {
+			l.body(pairs[j], event)
+		}
+	}
+
+}
+
+// LintRule checks the TCL of an iRule without sending it to a device. It
+// finds unbalanced braces, quotes and brackets, unknown events, deprecated
+// commands and common mistakes such as unbraced expressions or HTTP::
+// commands in events without HTTP.
+func LintRule(tcl string) []LintProblem {
+
+	cmds, problems := parseTcl(tcl, 1)
+	l := &ruleLinter{problems: problems, events: make(map[string]int)}
+	if len(problems) == 0 {
+		l.script(cmds, "")
+	}
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Line < l.problems[j].Line
+	})
+	return l.problems
+
+}
+
+// LintErrors counts the problems that are errors
+func LintErrors(problems []LintProblem) int {
+	n := 0
+	for _, p := range problems {
+		if p.Severity == LintError {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,169 @@
+package f5
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// texts returns the words of each command
+func texts(cmds []tclCommand) [][]string {
+	out := make([][]string, 0, len(cmds))
+	for _, c := range cmds {
+		words := make([]string, 0, len(c.words))
+		for _, w := range c.words {
+			words = append(words, w.text)
+		}
+		out = append(out, words)
+	}
+	return out
+}
+
+func TestParseTclWords(t *testing.T) {
+
+	tests := []struct {
+		name string
+		src  string
+		want [][]string
+	}{
+		{"bare words", "pool web_pool", [][]string{{"pool", "web_pool"}}},
+		{"commands split on newlines and semicolons", "set a 1\nset b 2; set c 3", [][]string{{"set", "a", "1"}, {"set", "b", "2"}, {"set", "c", "3"}}},
+		{"braces keep their contents", "if {$a == 1} { pool web }", [][]string{{"if", "$a == 1", " pool web "}}},
+		{"nested braces", "when HTTP_REQUEST { if {1} { drop } }", [][]string{{"when", "HTTP_REQUEST", " if {1} { drop } "}}},
+		{"quotes keep spaces", `log local0. "a b c"`, [][]string{{"log", "local0.", "a b c"}}},
+		{"brackets are part of a word", "set host [HTTP::host]", [][]string{{"set", "host", "[HTTP::host]"}}},
+		{"comments are skipped", "# a comment\npool web", [][]string{{"pool", "web"}}},
+		{"comments continue after a backslash", "# a comment \\\n still a comment\npool web", [][]string{{"pool", "web"}}},
+		{"lines continue after a backslash", "pool \\\n web", [][]string{{"pool", "web"}}},
+		{"escaped braces don't nest", `if {$a eq "\{"} { drop }`, [][]string{{"if", `$a eq "\{"`, " drop "}}},
+	}
+	for _, tt := range tests {
+		cmds, problems := parseTcl(tt.src, 1)
+		if len(problems) > 0 {
+			t.Errorf("%s: unexpected problems %v", tt.name, problems)
+			continue
+		}
+		if got := texts(cmds); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+}
+
+func TestParseTclSubstitutions(t *testing.T) {
+
+	cmds, problems := parseTcl(`set uri "[string tolower [HTTP::uri]]/x"`, 1)
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+	w := cmds[0].words[2]
+	if !w.quoted || len(w.subs) != 1 {
+		t.Fatalf("got quoted %v with %d substitutions, want a quoted word with 1", w.quoted, len(w.subs))
+	}
+	outer := w.subs[0][0]
+	if got := outer.words[0].text; got != "string" {
+		t.Errorf("got substituted command %s, want string", got)
+	}
+	if inner := outer.words[2].subs; len(inner) != 1 || inner[0][0].words[0].text != "HTTP::uri" {
+		t.Errorf("got %d nested substitutions, want HTTP::uri", len(inner))
+	}
+
+}
+
+func TestParseTclLines(t *testing.T) {
+
+	src := "when HTTP_REQUEST {\n  set a 1\n\n  if {$a} {\n    drop\n  }\n}"
+	cmds, _ := parseTcl(src, 1)
+	if cmds[0].line != 1 {
+		t.Errorf("got when on line %d, want 1", cmds[0].line)
+	}
+	body := cmds[0].words[2]
+	inner, _ := parseTcl(body.text, body.textLine)
+	lines := make([]int, 0)
+	for _, c := range inner {
+		lines = append(lines, c.line)
+	}
+	if want := []int{2, 4}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got commands on lines %v, want %v", lines, want)
+	}
+
+}
+
+func TestParseTclUnbalanced(t *testing.T) {
+
+	tests := []struct {
+		src     string
+		line    int
+		message string
+	}{
+		{"when HTTP_REQUEST {\n  drop\n", 1, "missing close-brace"},
+		{"drop\n}", 2, "unexpected close-brace"},
+		{"log local0. \"oops\n", 1, "missing close-quote"},
+		{"set a [HTTP::uri\n", 1, "missing close-bracket"},
+		{"set a {b}c", 1, "extra characters after close-brace"},
+		{`set a "b"c`, 1, "extra characters after close-quote"},
+	}
+	for _, tt := range tests {
+		_, problems := parseTcl(tt.src, 1)
+		if len(problems) != 1 {
+			t.Errorf("%q: got %d problems %v, want 1", tt.src, len(problems), problems)
+			continue
+		}
+		p := problems[0]
+		if p.Line != tt.line || p.Severity != LintError || !strings.Contains(p.Message, tt.message) {
+			t.Errorf("%q: got %s, want error on line %d containing %q", tt.src, p, tt.line, tt.message)
+		}
+	}
+
+}
+
+func TestLintRule(t *testing.T) {
+
+	tests := []struct {
+		name string
+		tcl  string
+		// the problems expected, eg. "2: error: unknown event"
+		want []string
+	}{
+		{"clean", "when HTTP_REQUEST {\n  if { [HTTP::host] eq \"a\" } {\n    pool web\n  }\n}", nil},
+		{"unknown event", "when HTTP_REQUESTS {\n  drop\n}", []string{"1: error: unknown event HTTP_REQUESTS"}},
+		{"command outside an event", "pool web", []string{"1: error: pool outside of an event"}},
+		{"unbraced body", "when HTTP_REQUEST drop", []string{"1: error: the body of when HTTP_REQUEST must be in braces"}},
+		{"nested when", "when HTTP_REQUEST {\n  when HTTP_RESPONSE { drop }\n}", []string{"2: error: when inside HTTP_REQUEST"}},
+		{"same event and priority twice", "when HTTP_REQUEST { drop }\nwhen HTTP_REQUEST { drop }", []string{"2: error: event HTTP_REQUEST is handled more than once"}},
+		{"different priorities", "when HTTP_REQUEST { drop }\nwhen HTTP_REQUEST priority 100 { drop }", nil},
+		{"deprecated command", "when HTTP_REQUEST {\n  if { [matchclass [IP::client_addr] equals blocked] } { drop }\n}", []string{"2: warning: matchclass is deprecated"}},
+		{"unbraced if", "when HTTP_REQUEST {\n  if $a { drop }\n}", []string{"2: warning: unbraced expression in if"}},
+		{"HTTP command without HTTP", "when CLIENT_ACCEPTED {\n  log local0. [HTTP::uri]\n}", []string{"2: error: HTTP::uri can't be used in CLIENT_ACCEPTED"}},
+		{"HTTP command in a braced condition", "when SERVER_CONNECTED {\n  if { [HTTP::host] eq \"a\" } { drop }\n}", []string{"2: error: HTTP::host can't be used in SERVER_CONNECTED"}},
+		{"HTTP::disable without HTTP", "when CLIENT_ACCEPTED {\n  if { [TCP::local_port] == 8443 } { HTTP::disable }\n}", nil},
+		{"HTTP::enable, collect and release without HTTP", "when CLIENT_ACCEPTED {\n  HTTP::enable\n  HTTP::collect 100\n  HTTP::release\n}", nil},
+		{"HTTP command in a proc", "proc host {} {\n  return [HTTP::host]\n}", nil},
+		{"global variable", "when HTTP_REQUEST {\n  set ::count 1\n}", []string{"2: warning: global variable ::count"}},
+		{"RULE_INIT without static", "when RULE_INIT {\n  set limit 10\n  set static::max 10\n}", []string{"2: warning: limit is lost once RULE_INIT is done"}},
+		{"unbalanced", "when HTTP_REQUEST {\n  drop\n", []string{"1: error: missing close-brace"}},
+		{"switch bodies", "when HTTP_REQUEST {\n  switch -glob [HTTP::uri] {\n    \"/a*\" -\n    \"/b*\" { pool web }\n    default { when HTTP_RESPONSE {} }\n  }\n}", []string{"5: error: when inside HTTP_REQUEST"}},
+	}
+	for _, tt := range tests {
+		problems := LintRule(tt.tcl)
+		if len(problems) != len(tt.want) {
+			t.Errorf("%s: got %d problems %v, want %d", tt.name, len(problems), problems, len(tt.want))
+			continue
+		}
+		for i, p := range problems {
+			if !strings.HasPrefix(p.String(), tt.want[i]) {
+				t.Errorf("%s: got %q, want it to start with %q", tt.name, p, tt.want[i])
+			}
+		}
+	}
+
+}
+
+func TestLintErrors(t *testing.T) {
+
+	problems := LintRule("when CLIENT_ACCEPTED {\n  if $a { log local0. [HTTP::uri] }\n}\nwhen FOO {}")
+	if got := LintErrors(problems); got != 2 {
+		t.Errorf("got %d errors in %v, want 2", got, problems)
+	}
+
+}
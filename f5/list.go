@@ -0,0 +1,177 @@
+package f5
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the number of items fetched with each request when listing a collection
+const defaultPageSize = 500
+
+// ListOptions narrow down the items returned when listing a collection
+type ListOptions struct {
+	// only list items in this partition
+	Partition string
+	// an iControl REST $filter expression, eg. "name eq webserver-80-pool"
+	Filter string
+	// only fetch these fields of each item
+	Fields []string
+	// items fetched with each request, 0 to fetch everything at once
+	PageSize int
+	// include subcollections in each item, eg. the members of each pool
+	Expand bool
+}
+
+func newListOptions(opts []func(*ListOptions)) ListOptions {
+	o := ListOptions{PageSize: defaultPageSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// query values are escaped by hand as the device expects the $ of each
+// option as is and spaces as %20
+func queryEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func (o ListOptions) query() string {
+
+	filters := make([]string, 0)
+	if p := strings.Trim(o.Partition, "/"); p != "" {
+		filters = append(filters, "partition eq "+p)
+	}
+	if o.Filter != "" {
+		filters = append(filters, o.Filter)
+	}
+
+	q := make([]string, 0)
+	if len(filters) > 0 {
+		q = append(q, "$filter="+queryEscape(strings.Join(filters, " and ")))
+	}
+	if len(o.Fields) > 0 {
+		q = append(q, "$select="+queryEscape(strings.Join(o.Fields, ",")))
+	}
+	if o.PageSize > 0 {
+		q = append(q, "$top="+strconv.Itoa(o.PageSize), "$skip=0")
+	}
+	if o.Expand {
+		q = append(q, "expandSubcollections=true")
+	}
+	return strings.Join(q, "&")
+
+}
+
+// followLink points a nextLink, which names the device as localhost, back at
+// the device
+func (f *Device) followLink(link string) string {
+	if link == "" {
+		return ""
+	}
+	l, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return f.Proto + "://" + f.Hostname + l.RequestURI()
+}
+
+// list fetches a collection page by page into res, which must point to a
+// struct with an Items slice
+func (f *Device) list(ctx context.Context, u string, res interface{}, opts []func(*ListOptions)) error {
+
+	if q := newListOptions(opts).query(); q != "" {
+		u += "?" + q
+	}
+
+	all := reflect.ValueOf(res).Elem()
+	items := all.FieldByName("Items")
+	first := true
+
+	for u != "" {
+
+		raw := json.RawMessage{}
+		err, _ := f.sendRequest(ctx, u, GET, nil, &raw)
+		if err != nil {
+			return err
+		}
+
+		page := reflect.New(all.Type())
+		if err := json.Unmarshal(raw, page.Interface()); err != nil {
+			return err
+		}
+		links := struct {
+			NextLink string `json:"nextLink"`
+		}{}
+		if err := json.Unmarshal(raw, &links); err != nil {
+			return err
+		}
+
+		pageItems := page.Elem().FieldByName("Items")
+		if first {
+			all.Set(page.Elem())
+			first = false
+		} else {
+			items.Set(reflect.AppendSlice(items, pageItems))
+		}
+
+		next := f.followLink(links.NextLink)
+		if next == u || pageItems.Len() == 0 {
+			break
+		}
+		u = next
+	}
+	return nil
+
+}
+
+// listAllPaths lists every object in each collection linked from an
+// organizing collection, eg. each type of profile under /mgmt/tm/ltm/profile.
+// The full path of each object is mapped to its type, eg. http.
+func (f *Device) listAllPaths(ctx context.Context, u string) (error, map[string]string) {
+
+	links := struct {
+		Items []struct {
+			Reference struct {
+				Link string `json:"link"`
+			} `json:"reference"`
+		} `json:"items"`
+	}{}
+	err, _ := f.sendRequest(ctx, u, GET, nil, &links)
+	if err != nil {
+		return err, nil
+	}
+
+	paths := make(map[string]string)
+	for _, item := range links.Items {
+		l, err := url.Parse(item.Reference.Link)
+		if err != nil {
+			continue
+		}
+		kind := path.Base(l.Path)
+		res := struct {
+			Items []struct {
+				FullPath string `json:"fullPath"`
+			} `json:"items"`
+		}{}
+		err = f.list(ctx, u+"/"+kind, &res, []func(*ListOptions){func(o *ListOptions) {
+			o.Fields = []string{"fullPath"}
+		}})
+		if IsNotFound(err) {
+			// types of modules that aren't provisioned
+			continue
+		} else if err != nil {
+			return err, nil
+		}
+		for _, obj := range res.Items {
+			paths[obj.FullPath] = kind
+		}
+	}
+	return nil, paths
+
+}
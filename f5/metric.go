@@ -0,0 +1,287 @@
+package f5
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type MetricType int
+
+const (
+	// a value that can go up and down, eg. current connections
+	GaugeMetric MetricType = iota
+	// a value that only ever goes up, eg. bits in
+	CounterMetric
+)
+
+func (t MetricType) String() string {
+	if t == CounterMetric {
+		return "counter"
+	}
+	return "gauge"
+}
+
+// Metric is a single statistic of an F5 object, independent of how it is output
+type Metric struct {
+	// the kind of object, eg. pool, poolmember, virtual, node or rule
+	Kind string
+	// the statistic, eg. serverside.curConns
+	Name      string
+	Partition string
+	// name of the object, the pool for pool members
+	Object string
+	// address and port of a pool member, eg. 10.1.1.1:80, or the event of an iRule
+	Member string
+	Value  float64
+	Type   MetricType
+	// when the statistic was fetched
+	Time time.Time
+}
+
+// statistics that only go up - the rest, like cur*, max* and avg*, are gauges
+var counterStats = map[string]bool{
+	"bitsin":          true,
+	"bitsout":         true,
+	"pktsin":          true,
+	"pktsout":         true,
+	"serviced":        true,
+	"failures":        true,
+	"aborts":          true,
+	"evictedconns":    true,
+	"slowkilled":      true,
+	"droppedpackets":  true,
+	"totalexecutions": true,
+}
+
+// metricType guesses from its name whether a statistic is a counter
+func metricType(name string) MetricType {
+	last := name
+	if i := strings.LastIndexAny(name, "._"); i >= 0 {
+		last = name[i+1:]
+	}
+	last = strings.ToLower(last)
+	if counterStats[last] || strings.HasPrefix(last, "tot") {
+		return CounterMetric
+	}
+	return GaugeMetric
+}
+
+func newMetric(kind string, partition string, object string, member string, name string, value float64, sampled time.Time) Metric {
+	return Metric{
+		Kind:      kind,
+		Name:      name,
+		Partition: partition,
+		Object:    object,
+		Member:    member,
+		Value:     value,
+		Type:      metricType(name),
+		Time:      sampled,
+	}
+}
+
+// appendMetric adds m to data unless it is zero and zero values are not wanted
+func (f *Device) appendMetric(data []Metric, m Metric) []Metric {
+	if m.Value > 0 || f.StatsShowZeroes {
+		return append(data, m)
+	}
+	return data
+}
+
+// Labels identify the object the metric belongs to
+func (m Metric) Labels() map[string]string {
+	labels := map[string]string{
+		"object": m.Object,
+	}
+	if m.Partition != "" {
+		labels["partition"] = m.Partition
+	}
+	if m.Member != "" {
+		labels["member"] = m.Member
+	}
+	return labels
+}
+
+// graphite paths are split on spaces
+var graphiteEscaper = strings.NewReplacer(" ", "_")
+
+// the kinds of object whose statistics have always been sent to graphite
+var legacyGraphiteKinds = map[string]bool{
+	"pool":       true,
+	"poolmember": true,
+	"virtual":    true,
+	"node":       true,
+	"rule":       true,
+}
+
+// graphiteName is the name a statistic has always had in graphite, eg.
+// Serverside_curConns for serverside.curConns and CurSessions for curSessions.
+// Statistics that have only been sent since, like the system ones, keep the
+// name the device gives them.
+func (m Metric) graphiteName() string {
+	if m.Name == "" || !legacyGraphiteKinds[m.Kind] {
+		return m.Name
+	}
+	return strings.ToUpper(m.Name[:1]) + strings.Replace(m.Name[1:], ".", "_", -1)
+}
+
+// GraphiteKey is the dotted path of the metric, eg. f5.Common.pool.web-pool.CurSessions
+// or f5.system.cpu.0.1.fiveSecAvgUser for system stats, which have no partition
+func (m Metric) GraphiteKey(prefix string) string {
+	partition := m.Partition
+	if partition == "" {
+		partition = "system"
+	}
+	name := m.graphiteName()
+	var key string
+	switch {
+	case m.Kind == "poolmember":
+		// as in the rest of the path, colons become dashes
+		member := strings.Replace(m.Member, ":", "-", -1)
+		key = partition + ".pool." + m.Object + ".poolmember." + member + "." + name
+	case m.Kind == "rule" && m.Member != "":
+		// the event stays part of the rule name, as the device gives it, eg.
+		// f5.Common.rule.redirect:HTTP_REQUEST.TotalExecutions
+		key = partition + ".rule." + m.Object + ":" + m.Member + "." + name
+	case m.Member != "":
+		key = partition + "." + m.Kind + "." + m.Object + "." + m.Member + "." + name
+	default:
+		key = partition + "." + m.Kind + "." + m.Object + "." + name
+	}
+	return prefix + graphiteEscaper.Replace(key)
+}
+
+// GraphiteDataPoints prefixes each metric path with the stats path prefix
+func (f *Device) GraphiteDataPoints(metrics []Metric) []GraphiteDataPoint {
+	data := make([]GraphiteDataPoint, 0, len(metrics))
+	for _, m := range metrics {
+		data = append(data, NewGraphiteDataPoint(m.GraphiteKey(f.StatsPathPrefix), m.Value, m.Time.Unix()))
+	}
+	return data
+}
+
+// promName turns a statistic like serverside.curConns or Serverside_curConns
+// into serverside_cur_conns
+func promName(s string) string {
+	var b strings.Builder
+	prev := '_'
+	for i, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && prev != '_' && !unicode.IsUpper(prev) {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = '_'
+		}
+		if r == '_' && prev == '_' {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// PrometheusName is the name of the metric family, eg. f5_pool_cur_sessions
+func (m Metric) PrometheusName(namespace string) string {
+	name := promName(namespace) + "_" + promName(m.Kind) + "_" + promName(m.Name)
+	if m.Type == CounterMetric && !strings.HasSuffix(name, "_total") {
+		name += "_total"
+	}
+	return name
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + `="` + labelEscaper.Replace(labels[k]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WritePrometheus writes metrics in the Prometheus text exposition format,
+// grouped into one family per name. namespace prefixes every name, eg. f5.
+func WritePrometheus(w io.Writer, namespace string, metrics []Metric) error {
+
+	families := make(map[string][]Metric)
+	for _, m := range metrics {
+		name := m.PrometheusName(namespace)
+		families[name] = append(families[name], m)
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		family := families[name]
+		fmt.Fprintf(bw, "# HELP %s F5 %s statistic %s\n", name, family[0].Kind, family[0].Name)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, family[0].Type)
+		for _, m := range family {
+			fmt.Fprintf(bw, "%s%s %s\n", name, promLabels(m.Labels()), strconv.FormatFloat(m.Value, 'g', -1, 64))
+		}
+	}
+	return bw.Flush()
+
+}
+
+// Series identifies a statistic of one object from one sample to the next
+func (m Metric) Series() string {
+	return m.Kind + "|" + m.Partition + "|" + m.Object + "|" + m.Member + "|" + m.Name
+}
+
+// MetricRate is how much a statistic changed between two samples
+type MetricRate struct {
+	Metric
+	// change since the previous sample
+	Delta float64
+	// change each second, only for counters
+	PerSecond float64
+}
+
+// Rates compares two samples of the same statistics. A counter that went
+// backwards has been reset and is counted from zero. Statistics that are not
+// in both samples are left out.
+func Rates(prev []Metric, cur []Metric) []MetricRate {
+
+	before := make(map[string]Metric, len(prev))
+	for _, m := range prev {
+		before[m.Series()] = m
+	}
+
+	rates := make([]MetricRate, 0, len(cur))
+	for _, m := range cur {
+		p, ok := before[m.Series()]
+		if !ok {
+			continue
+		}
+		r := MetricRate{Metric: m, Delta: m.Value - p.Value}
+		if m.Type == CounterMetric {
+			if r.Delta < 0 {
+				r.Delta = m.Value
+			}
+			if secs := m.Time.Sub(p.Time).Seconds(); secs > 0 {
+				r.PerSecond = r.Delta / secs
+			}
+		}
+		rates = append(rates, r)
+	}
+	return rates
+
+}
@@ -0,0 +1,73 @@
+package f5
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGraphiteKeys(t *testing.T) {
+
+	tests := []struct {
+		link string
+		stat string
+		want string
+	}{
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/stats", "serverside.curConns", "f5.Common.pool.web.Serverside_curConns"},
+		{"https://localhost/mgmt/tm/ltm/pool/~DMZ~web/stats", "curSessions", "f5.DMZ.pool.web.CurSessions"},
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/members/~Common~10.1.1.1:80/stats", "serverside.bitsIn", "f5.Common.pool.web.poolmember.10.1.1.1-80.Serverside_bitsIn"},
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/members/~Common~web01:8080/stats", "totRequests", "f5.Common.pool.web.poolmember.web01-8080.TotRequests"},
+		{"https://localhost/mgmt/tm/ltm/virtual/~DMZ~virtual-prd/stats", "clientside.curConns", "f5.DMZ.virtual.virtual-prd.Clientside_curConns"},
+		{"https://localhost/mgmt/tm/ltm/virtual/~DMZ~virtual-prd/stats", "fiveMinAvgUsageRatio", "f5.DMZ.virtual.virtual-prd.FiveMinAvgUsageRatio"},
+		{"https://localhost/mgmt/tm/ltm/node/~Common~10.1.1.1/stats", "serverside.totConns", "f5.Common.node.10.1.1.1.Serverside_totConns"},
+		{"https://localhost/mgmt/tm/ltm/rule/~Common~redirect:HTTP_REQUEST/stats", "totalExecutions", "f5.Common.rule.redirect:HTTP_REQUEST.TotalExecutions"},
+		{"https://localhost/mgmt/tm/ltm/rule/~Common~redirect:HTTP_REQUEST/stats", "avgCycles", "f5.Common.rule.redirect:HTTP_REQUEST.AvgCycles"},
+	}
+
+	f := &Device{}
+	for _, tt := range tests {
+		body := `{"entries":{"` + tt.link + `":{"nestedStats":{"entries":{"` + tt.stat + `":{"value":1}}}}}}`
+		tree := &LBStatsTree{}
+		if err := json.Unmarshal([]byte(body), tree); err != nil {
+			t.Fatal(err)
+		}
+		metrics := f.metrics(tree)
+		if len(metrics) != 1 {
+			t.Errorf("%s %s: got %d metrics, want 1", tt.link, tt.stat, len(metrics))
+			continue
+		}
+		if got := metrics[0].GraphiteKey("f5."); got != tt.want {
+			t.Errorf("%s %s: got %s, want %s", tt.link, tt.stat, got, tt.want)
+		}
+	}
+
+}
+
+func TestParseStatsPath(t *testing.T) {
+
+	tests := []struct {
+		link string
+		want StatsPath
+	}{
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/stats", StatsPath{Kind: "pool", Partition: "Common", Object: "web"}},
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~app~web/stats", StatsPath{Kind: "pool", Partition: "Common", Object: "app/web"}},
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/~Common~web/stats", StatsPath{Kind: "pool", Partition: "Common", Object: "web"}},
+		{"https://localhost/mgmt/tm/ltm/pool/~Common~web/members/~Common~10.1.1.1:80/stats", StatsPath{Kind: "pool", Partition: "Common", Object: "web", Subcollection: "members", Member: "10.1.1.1:80"}},
+		{"https://localhost/mgmt/tm/ltm/rule/~Common~redirect:HTTP_REQUEST/stats", StatsPath{Kind: "rule", Partition: "Common", Object: "redirect", Member: "HTTP_REQUEST"}},
+		{"https://localhost/mgmt/tm/ltm/node/~DMZ~10.1.1.1%256/stats", StatsPath{Kind: "node", Partition: "DMZ", Object: "10.1.1.1%6"}},
+	}
+	for _, tt := range tests {
+		err, got := ParseStatsPath(tt.link)
+		if err != nil {
+			t.Errorf("%s: %s", tt.link, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.link, got, tt.want)
+		}
+	}
+
+	if err, _ := ParseStatsPath("https://localhost/mgmt/tm/ltm/pool/stats"); err == nil {
+		t.Errorf("got no error for a url without an object")
+	}
+
+}
@@ -1,9 +1,9 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -41,12 +41,17 @@ type LBMonitorHttpRef struct {
 	Items []LBMonitorHttp `json:"items"`
 }
 
-func (f *Device) ShowMonitorsHttp() (error, *LBMonitorHttpRef) {
+// MonitorPaths maps the full path of every monitor, of any type, to its type
+func (f *Device) MonitorPaths(ctx context.Context) (error, map[string]string) {
+	return f.listAllPaths(ctx, f.Proto+"://"+f.Hostname+"/mgmt/tm/ltm/monitor")
+}
+
+func (f *Device) ShowMonitorsHttp(ctx context.Context, opts ...func(*ListOptions)) (error, *LBMonitorHttpRef) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/monitor/http"
 	res := LBMonitorHttpRef{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -55,13 +60,13 @@ func (f *Device) ShowMonitorsHttp() (error, *LBMonitorHttpRef) {
 
 }
 
-func (f *Device) ShowMonitorHttp(vname string) (error, *LBMonitorHttp) {
+func (f *Device) ShowMonitorHttp(ctx context.Context, vname string) (error, *LBMonitorHttp) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/monitor/http/" + vname + "?expandSubcollections=true"
 	res := LBMonitorHttp{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -70,13 +75,13 @@ func (f *Device) ShowMonitorHttp(vname string) (error, *LBMonitorHttp) {
 
 }
 
-func (f *Device) AddMonitorHttp(body *json.RawMessage) (error, *LBMonitorHttp) {
+func (f *Device) AddMonitorHttp(ctx context.Context, body *json.RawMessage) (error, *LBMonitorHttp) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/monitor/http"
 	res := LBMonitorHttp{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -84,14 +89,14 @@ func (f *Device) AddMonitorHttp(body *json.RawMessage) (error, *LBMonitorHttp) {
 	}
 }
 
-func (f *Device) UpdateMonitorHttp(vname string, body *json.RawMessage) (error, *LBMonitorHttp) {
+func (f *Device) UpdateMonitorHttp(ctx context.Context, vname string, body *json.RawMessage) (error, *LBMonitorHttp) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/monitor/http/" + vname
 	res := LBMonitorHttp{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -100,8 +105,8 @@ func (f *Device) UpdateMonitorHttp(vname string, body *json.RawMessage) (error,
 
 }
 
-func (f *Device) PatchMonitorHttp(name string, patch *LBMonitorHttp) (error, *LBMonitorHttp) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchMonitorHttp(ctx context.Context, name string, patch *LBMonitorHttp) (error, *LBMonitorHttp) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/monitor/http/%s", f.Proto, f.Hostname, name)
 	existing := &LBMonitorHttp{}
 	var err error
@@ -110,7 +115,7 @@ func (f *Device) PatchMonitorHttp(name string, patch *LBMonitorHttp) (error, *LB
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowMonitorHttp(name)
+		err, existing = f.ShowMonitorHttp(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -133,7 +138,7 @@ func (f *Device) PatchMonitorHttp(name string, patch *LBMonitorHttp) (error, *LB
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -142,13 +147,13 @@ func (f *Device) PatchMonitorHttp(name string, patch *LBMonitorHttp) (error, *LB
 		}
 	}
 }
-func (f *Device) DeleteMonitorHttp(vname string) (error, *Response) {
+func (f *Device) DeleteMonitorHttp(ctx context.Context, vname string) (error, *Response) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/monitor/http/" + vname
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
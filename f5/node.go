@@ -1,6 +1,7 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -69,58 +70,12 @@ type LBNodeUpdate struct {
 	RateLimit       string           `json:"rateLimit,omitempty"`
 }
 
-type LBNodeStatsDescription struct {
-	Description string `json:"description,omitempty"`
-}
-
-type LBNodeStatsValue struct {
-	Value int `json:"value,omitempty"`
-}
-
-type LBNodeStatsInnerEntries struct {
-	Addr                     LBNodeStatsDescription `json:"addr,omitempty"`
-	CurSessions              LBStatsValue           `json:"curSessions,omitempty"`
-	MonitorRule              LBNodeStatsDescription `json:"monitorRule,omitempty"`
-	MonitorStatus            LBNodeStatsDescription `json:"monitorStatus,omitempty"`
-	TmName                   LBNodeStatsDescription `json:"tmName,omitempty"`
-	Serverside_bitsIn        LBStatsValue           `json:"serverside.bitsIn,omitempty"`
-	Serverside_bitsOut       LBStatsValue           `json:"serverside.bitsOut,omitempty"`
-	Serverside_curConns      LBStatsValue           `json:"serverside.curConns,omitempty"`
-	Serverside_maxConns      LBStatsValue           `json:"serverside.maxConns,omitempty"`
-	Serverside_pktsIn        LBStatsValue           `json:"serverside.pktsIn,omitempty"`
-	Serverside_pktsOut       LBStatsValue           `json:"serverside.pktsOut,omitempty"`
-	Serverside_totConns      LBStatsValue           `json:"serverside.totConns,omitempty"`
-	SessionStatus            LBNodeStatsDescription `json:"sessionStatus,omitempty"`
-	Status_availabilityState LBNodeStatsDescription `json:"status.availabilityState,omitempty"`
-	Status_enabledState      LBNodeStatsDescription `json:"status.enabledState,omitempty"`
-	Status_statusReason      LBNodeStatsDescription `json:"status.statusReason,omitempty"`
-	TotRequests              LBStatsValue           `json:"totRequests,omitempty"`
-}
-
-type LBNodeStatsNestedStats struct {
-	Kind     string                  `json:"kind,omitempty"`
-	SelfLink string                  `json:"selfLink,omitempty"`
-	Entries  LBNodeStatsInnerEntries `json:"entries,omitempty"`
-}
-
-type LBNodeURLKey struct {
-	NestedStats LBNodeStatsNestedStats `json:"nestedStats,omitempty"`
-}
-type LBNodeStatsOuterEntries map[string]LBNodeURLKey
-
-type LBNodeStats struct {
-	Kind       string                  `json:"kind,omitempty"`
-	Generation int                     `json:"generation,omitempty"`
-	SelfLink   string                  `json:"selfLink,omitempty"`
-	Entries    LBNodeStatsOuterEntries `json:"entries,omitempty"`
-}
-
-func (f *Device) ShowNodes() (error, *LBNodes) {
+func (f *Device) ShowNodes(ctx context.Context, opts ...func(*ListOptions)) (error, *LBNodes) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node"
 	res := LBNodes{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -129,14 +84,14 @@ func (f *Device) ShowNodes() (error, *LBNodes) {
 
 }
 
-func (f *Device) ShowNode(nname string) (error, *LBNode) {
+func (f *Device) ShowNode(ctx context.Context, nname string) (error, *LBNode) {
 
 	//u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/~" + partition + "~" + pname + "?expandSubcollections=true"
-	node := strings.Replace(nname, "/", "~", -1)
+	node := f.escapeName(nname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/" + node
 	res := LBNode{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -145,13 +100,13 @@ func (f *Device) ShowNode(nname string) (error, *LBNode) {
 
 }
 
-func (f *Device) ShowNodeStats(nname string) (error, *LBObjectStats) {
+func (f *Device) ShowNodeStats(ctx context.Context, nname string) (error, *LBStatsTree) {
 
-	node := strings.Replace(nname, "/", "~", -1)
+	node := f.escapeName(nname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/" + node + "/stats"
-	res := LBObjectStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -160,12 +115,12 @@ func (f *Device) ShowNodeStats(nname string) (error, *LBObjectStats) {
 
 }
 
-func (f *Device) ShowAllNodeStats() (error, *LBNodeStats) {
+func (f *Device) ShowAllNodeStats(ctx context.Context) (error, *LBStatsTree) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/stats"
-	res := LBNodeStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -174,13 +129,13 @@ func (f *Device) ShowAllNodeStats() (error, *LBNodeStats) {
 
 }
 
-func (f *Device) AddNode(body *json.RawMessage) (error, *LBNode) {
+func (f *Device) AddNode(ctx context.Context, body *json.RawMessage) (error, *LBNode) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node"
 	res := LBNode{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -189,14 +144,14 @@ func (f *Device) AddNode(body *json.RawMessage) (error, *LBNode) {
 
 }
 
-func (f *Device) UpdateNode(nname string, body *json.RawMessage) (error, *LBNode) {
+func (f *Device) UpdateNode(ctx context.Context, nname string, body *json.RawMessage) (error, *LBNode) {
 
-	node := strings.Replace(nname, "/", "~", -1)
+	node := f.escapeName(nname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/" + node
 	res := LBNode{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -204,8 +159,8 @@ func (f *Device) UpdateNode(nname string, body *json.RawMessage) (error, *LBNode
 	}
 }
 
-func (f *Device) PatchNode(name string, patch *LBNode) (error, *LBNode) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchNode(ctx context.Context, name string, patch *LBNode) (error, *LBNode) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/node/%s", f.Proto, f.Hostname, name)
 	existing := &LBNode{}
 	var err error
@@ -214,7 +169,7 @@ func (f *Device) PatchNode(name string, patch *LBNode) (error, *LBNode) {
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowNode(name)
+		err, existing = f.ShowNode(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -237,7 +192,7 @@ func (f *Device) PatchNode(name string, patch *LBNode) (error, *LBNode) {
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -247,13 +202,95 @@ func (f *Device) PatchNode(name string, patch *LBNode) (error, *LBNode) {
 	}
 }
 
-func (f *Device) DeleteNode(nname string) (error, *Response) {
+func (f *Device) DeleteNode(ctx context.Context, nname string) (error, *Response) {
+
+	node := f.escapeName(nname)
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/" + node
+	res := json.RawMessage{}
+
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, resp
+	}
+
+}
+
+// FindNode finds a node by its name, eg. /Common/web01 or web01, or by its address
+func (f *Device) FindNode(ctx context.Context, node string) (error, *LBNode) {
+
+	err, res := f.ShowNodes(ctx)
+	if err != nil {
+		return err, nil
+	}
+
+	name := node
+	if !strings.HasPrefix(name, "/") && f.Partition != "" {
+		name = "/" + f.Partition + "/" + name
+	}
+	found := make([]LBNode, 0)
+	for _, n := range res.Items {
+		if n.FullPath == name || n.Name == node || n.Address == node {
+			found = append(found, n)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return fmt.Errorf("error: no node named or with the address %s", node), nil
+	case 1:
+		return nil, &found[0]
+	}
+	names := make([]string, len(found))
+	for i, n := range found {
+		names[i] = n.FullPath
+	}
+	return fmt.Errorf("error: %s matches more than one node : %s", node, strings.Join(names, ", ")), nil
+
+}
+
+// LBNodeMember is a pool member that references a node
+type LBNodeMember struct {
+	// the pool the member is in, eg. /Common/web-pool
+	Pool string
+	LBPoolMember
+}
+
+// NodeMembers returns the members of every pool that reference a node
+func (f *Device) NodeMembers(ctx context.Context, node *LBNode) (error, []LBNodeMember) {
+
+	err, pools := f.ShowPools(ctx, func(o *ListOptions) {
+		o.Expand = true
+	})
+	if err != nil {
+		return err, nil
+	}
+
+	members := make([]LBNodeMember, 0)
+	for _, pool := range pools.Items {
+		for _, m := range pool.Members {
+			// a member is named after its node and port, eg. web01:80, or web01.80 for IPv6
+			i := strings.LastIndexAny(m.Name, ":.")
+			named := i > 0 && m.Partition == node.Partition && m.Name[:i] == node.Name
+			if named || (m.Address != "" && m.Address == node.Address) {
+				if m.FullPath == "" {
+					m.FullPath = "/" + m.Partition + "/" + m.Name
+				}
+				members = append(members, LBNodeMember{Pool: pool.FullPath, LBPoolMember: m})
+			}
+		}
+	}
+	return nil, members
+
+}
+
+func (f *Device) setNodeState(ctx context.Context, nname string, state LBPoolMemberState) (error, *Response) {
 
-	node := strings.Replace(nname, "/", "~", -1)
+	node := f.escapeName(nname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/node/" + node
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, PATCH, &state, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -261,3 +298,18 @@ func (f *Device) DeleteNode(nname string) (error, *Response) {
 	}
 
 }
+
+// OnlineNode enables a node and marks it up, in every pool it is a member of
+func (f *Device) OnlineNode(ctx context.Context, nname string) (error, *Response) {
+	return f.setNodeState(ctx, nname, LBPoolMemberState{"user-up", "user-enabled"})
+}
+
+// OfflineNode disables a node so it takes no new connections
+func (f *Device) OfflineNode(ctx context.Context, nname string) (error, *Response) {
+	return f.setNodeState(ctx, nname, LBPoolMemberState{"user-up", "user-disabled"})
+}
+
+// OfflineNodeForced forces a node offline, dropping its connections
+func (f *Device) OfflineNodeForced(ctx context.Context, nname string) (error, *Response) {
+	return f.setNodeState(ctx, nname, LBPoolMemberState{"user-down", "user-disabled"})
+}
@@ -1,9 +1,9 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -179,12 +179,12 @@ type LBPolicies struct {
 	Items []LBPolicy `json:"items,omitempty"`
 }
 
-func (f *Device) ShowPolicies() (error, *LBPolicies) {
+func (f *Device) ShowPolicies(ctx context.Context, opts ...func(*ListOptions)) (error, *LBPolicies) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy"
 	res := LBPolicies{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -193,13 +193,13 @@ func (f *Device) ShowPolicies() (error, *LBPolicies) {
 
 }
 
-func (f *Device) ShowPolicy(pname string) (error, *LBPolicy) {
+func (f *Device) ShowPolicy(ctx context.Context, pname string) (error, *LBPolicy) {
 
-	policy := strings.Replace(pname, "/", "~", -1)
+	policy := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy/" + policy + "?expandSubcollections=true"
 	res := LBPolicy{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -208,13 +208,13 @@ func (f *Device) ShowPolicy(pname string) (error, *LBPolicy) {
 
 }
 
-func (f *Device) AddPolicy(body *json.RawMessage) (error, *LBPolicy) {
+func (f *Device) AddPolicy(ctx context.Context, body *json.RawMessage) (error, *LBPolicy) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy"
 	res := LBPolicy{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -223,14 +223,14 @@ func (f *Device) AddPolicy(body *json.RawMessage) (error, *LBPolicy) {
 
 }
 
-func (f *Device) UpdatePolicy(pname string, body *json.RawMessage) (error, *LBPolicy) {
+func (f *Device) UpdatePolicy(ctx context.Context, pname string, body *json.RawMessage) (error, *LBPolicy) {
 
-	policy := strings.Replace(pname, "/", "~", -1)
+	policy := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy/" + policy
 	res := LBPolicy{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -238,8 +238,8 @@ func (f *Device) UpdatePolicy(pname string, body *json.RawMessage) (error, *LBPo
 	}
 }
 
-func (f *Device) PatchPolicy(name string, patch *LBPolicy) (error, *LBPolicy) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchPolicy(ctx context.Context, name string, patch *LBPolicy) (error, *LBPolicy) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/policy/%s", f.Proto, f.Hostname, name)
 	existing := &LBPolicy{}
 	var err error
@@ -248,7 +248,7 @@ func (f *Device) PatchPolicy(name string, patch *LBPolicy) (error, *LBPolicy) {
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowPolicy(name)
+		err, existing = f.ShowPolicy(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -271,7 +271,7 @@ func (f *Device) PatchPolicy(name string, patch *LBPolicy) (error, *LBPolicy) {
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -281,14 +281,14 @@ func (f *Device) PatchPolicy(name string, patch *LBPolicy) (error, *LBPolicy) {
 	}
 }
 
-func (f *Device) DeletePolicy(pname string) (error, *Response) {
+func (f *Device) DeletePolicy(ctx context.Context, pname string) (error, *Response) {
 
 	//u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy/~" + partition + "~" + pname + "?expandSubcollections=true"
-	policy := strings.Replace(pname, "/", "~", -1)
+	policy := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/policy/" + policy
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
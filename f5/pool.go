@@ -1,9 +1,11 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -113,69 +115,12 @@ type LBPools struct {
 	Items []LBPool `json:"items"`
 }
 
-type LBPoolStatsDescription struct {
-	Description string `json:"description"`
-}
-
-type LBPoolStatsValue struct {
-	Value int `json:"value"`
-}
-
-type LBPoolStatsInnerEntries struct {
-	ActiveMemberCnt          LBStatsValue           `json:"activeMemberCnt"`
-	ConnqAll_ageEdm          LBStatsValue           `json:"connqAll.ageEdm"`
-	ConnqAll_ageEma          LBStatsValue           `json:"connqAll.ageEma"`
-	ConnqAll_ageHead         LBStatsValue           `json:"connqAll.ageHead"`
-	ConnqAll_ageMax          LBStatsValue           `json:"connqAll.ageMax"`
-	ConnqAll_depth           LBStatsValue           `json:"connqAll.depth"`
-	ConnqAll_serviced        LBStatsValue           `json:"connqAll.serviced"`
-	Connq_ageEdm             LBStatsValue           `json:"connq.ageEdm"`
-	Connq_ageEma             LBStatsValue           `json:"connq.ageEma"`
-	Connq_ageHead            LBStatsValue           `json:"connq.ageHead"`
-	Connq_ageMax             LBStatsValue           `json:"connq.ageMax"`
-	Connq_depth              LBStatsValue           `json:"connq.depth"`
-	Connq_serviced           LBStatsValue           `json:"connq.serviced"`
-	CurSessions              LBStatsValue           `json:"curSessions"`
-	MinActiveMembers         LBStatsValue           `json:"minActiveMembers"`
-	MonitorRule              LBPoolStatsDescription `json:"monitorRule"`
-	TmName                   LBPoolStatsDescription `json:"tmName"`
-	Serverside_bitsIn        LBStatsValue           `json:"serverside.bitsIn"`
-	Serverside_bitsOut       LBStatsValue           `json:"serverside.bitsOut"`
-	Serverside_curConns      LBStatsValue           `json:"serverside.curConns"`
-	Serverside_maxConns      LBStatsValue           `json:"serverside.maxConns"`
-	Serverside_pktsIn        LBStatsValue           `json:"serverside.pktsIn"`
-	Serverside_pktsOut       LBStatsValue           `json:"serverside.pktsOut"`
-	Serverside_totConns      LBStatsValue           `json:"serverside.totConns"`
-	Status_availabilityState LBPoolStatsDescription `json:"status.availabilityState"`
-	Status_enabledState      LBPoolStatsDescription `json:"status.enabledState"`
-	Status_statusReason      LBPoolStatsDescription `json:"status.statusReason"`
-	TotRequests              LBStatsValue           `json:"totRequests"`
-}
-
-type LBPoolStatsNestedStats struct {
-	Kind     string                  `json:"kind"`
-	SelfLink string                  `json:"selfLink"`
-	Entries  LBPoolStatsInnerEntries `json:"entries"`
-}
-
-type LBPoolURLKey struct {
-	NestedStats LBPoolStatsNestedStats `json:"nestedStats"`
-}
-type LBPoolStatsOuterEntries map[string]LBPoolURLKey
-
-type LBPoolStats struct {
-	Kind       string                  `json:"kind"`
-	Generation int                     `json:"generation"`
-	SelfLink   string                  `json:"selfLink"`
-	Entries    LBPoolStatsOuterEntries `json:"entries"`
-}
-
-func (f *Device) ShowPools() (error, *LBPools) {
+func (f *Device) ShowPools(ctx context.Context, opts ...func(*ListOptions)) (error, *LBPools) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool"
 	res := LBPools{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -184,13 +129,13 @@ func (f *Device) ShowPools() (error, *LBPools) {
 
 }
 
-func (f *Device) ShowPool(pname string) (error, *LBPool) {
+func (f *Device) ShowPool(ctx context.Context, pname string) (error, *LBPool) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "?expandSubcollections=true"
 	res := LBPool{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -199,13 +144,13 @@ func (f *Device) ShowPool(pname string) (error, *LBPool) {
 
 }
 
-func (f *Device) ShowPoolStats(pname string) (error, *LBObjectStats) {
+func (f *Device) ShowPoolStats(ctx context.Context, pname string) (error, *LBStatsTree) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/stats"
-	res := LBObjectStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -213,12 +158,12 @@ func (f *Device) ShowPoolStats(pname string) (error, *LBObjectStats) {
 	}
 }
 
-func (f *Device) ShowAllPoolStats() (error, *LBPoolStats) {
+func (f *Device) ShowAllPoolStats(ctx context.Context) (error, *LBStatsTree) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/stats"
-	res := LBPoolStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -226,7 +171,7 @@ func (f *Device) ShowAllPoolStats() (error, *LBPoolStats) {
 	}
 }
 
-func (f *Device) AddPool(body *json.RawMessage) (error, *LBPool) {
+func (f *Device) AddPool(ctx context.Context, body *json.RawMessage) (error, *LBPool) {
 	// we use json.RawMessage so we can modify the input file without using a struct
 	// use of a struct will send all available fields, some of which can't be modified
 
@@ -234,7 +179,7 @@ func (f *Device) AddPool(body *json.RawMessage) (error, *LBPool) {
 	res := LBPool{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -243,14 +188,14 @@ func (f *Device) AddPool(body *json.RawMessage) (error, *LBPool) {
 
 }
 
-func (f *Device) UpdatePool(pname string, body *json.RawMessage) (error, *LBPool) {
+func (f *Device) UpdatePool(ctx context.Context, pname string, body *json.RawMessage) (error, *LBPool) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool
 	res := LBPool{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -258,8 +203,8 @@ func (f *Device) UpdatePool(pname string, body *json.RawMessage) (error, *LBPool
 	}
 }
 
-func (f *Device) PatchPool(name string, patch *LBPool) (error, *LBPool) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchPool(ctx context.Context, name string, patch *LBPool) (error, *LBPool) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/pool/%s", f.Proto, f.Hostname, name)
 	existing := &LBPool{}
 	var err error
@@ -268,7 +213,7 @@ func (f *Device) PatchPool(name string, patch *LBPool) (error, *LBPool) {
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowPool(name)
+		err, existing = f.ShowPool(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -291,7 +236,7 @@ func (f *Device) PatchPool(name string, patch *LBPool) (error, *LBPool) {
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -301,13 +246,13 @@ func (f *Device) PatchPool(name string, patch *LBPool) (error, *LBPool) {
 	}
 }
 
-func (f *Device) DeletePool(pname string) (error, *Response) {
+func (f *Device) DeletePool(ctx context.Context, pname string) (error, *Response) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -316,13 +261,13 @@ func (f *Device) DeletePool(pname string) (error, *Response) {
 
 }
 
-func (f *Device) ShowPoolMembers(pname string) (error, *LBPoolMembers) {
+func (f *Device) ShowPoolMembers(ctx context.Context, pname string, opts ...func(*ListOptions)) (error, *LBPoolMembers) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members"
 	res := LBPoolMembers{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -331,13 +276,13 @@ func (f *Device) ShowPoolMembers(pname string) (error, *LBPoolMembers) {
 
 }
 
-func (f *Device) ShowPoolMembersStats(pname string) (error, *LBPoolStats) {
+func (f *Device) ShowPoolMembersStats(ctx context.Context, pname string) (error, *LBStatsTree) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members/stats"
-	res := LBPoolStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -345,12 +290,12 @@ func (f *Device) ShowPoolMembersStats(pname string) (error, *LBPoolStats) {
 	}
 }
 
-func (f *Device) ShowAllPoolMembersStats() (error, *LBPoolStats) {
+func (f *Device) ShowAllPoolMembersStats(ctx context.Context) (error, *LBStatsTree) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/members/stats"
-	res := LBPoolStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -358,14 +303,14 @@ func (f *Device) ShowAllPoolMembersStats() (error, *LBPoolStats) {
 	}
 }
 
-func (f *Device) AddPoolMembers(pname string, body *json.RawMessage) (error, *LBPoolMembers) {
+func (f *Device) AddPoolMembers(ctx context.Context, pname string, body *json.RawMessage) (error, *LBPoolMembers) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members"
 	res := LBPoolMembers{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -374,14 +319,14 @@ func (f *Device) AddPoolMembers(pname string, body *json.RawMessage) (error, *LB
 
 }
 
-func (f *Device) UpdatePoolMembers(pname string, body *json.RawMessage) (error, *LBPoolMembers) {
+func (f *Device) UpdatePoolMembers(ctx context.Context, pname string, body *json.RawMessage) (error, *LBPoolMembers) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members"
 	res := LBPoolMembers{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -390,13 +335,13 @@ func (f *Device) UpdatePoolMembers(pname string, body *json.RawMessage) (error,
 
 }
 
-func (f *Device) DeletePoolMembers(pname string) (error, *Response) {
+func (f *Device) DeletePoolMembers(ctx context.Context, pname string) (error, *Response) {
 
-	pool := strings.Replace(pname, "/", "~", -1)
+	pool := f.escapeName(pname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members"
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -405,10 +350,10 @@ func (f *Device) DeletePoolMembers(pname string) (error, *Response) {
 
 }
 
-func (f *Device) OnlinePoolMember(pname string, mname string) (error, *Response) {
+func (f *Device) OnlinePoolMember(ctx context.Context, pname string, mname string) (error, *Response) {
 
-	pmember := strings.Replace(mname, "/", "~", -1)
-	pool := strings.Replace(pname, "/", "~", -1)
+	pmember := f.escapeName(mname)
+	pool := f.escapeName(pname)
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members/" + pmember
 	res := json.RawMessage{}
@@ -421,7 +366,7 @@ func (f *Device) OnlinePoolMember(pname string, mname string) (error, *Response)
 	body := LBPoolMemberState{"user-up", "user-enabled"}
 
 	// put the request
-	err, resp := f.sendRequest(u, PUT, &body, &res)
+	err, resp := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -430,10 +375,10 @@ func (f *Device) OnlinePoolMember(pname string, mname string) (error, *Response)
 
 }
 
-func (f *Device) OfflinePoolMember(pname string, mname string) (error, *Response) {
+func (f *Device) OfflinePoolMember(ctx context.Context, pname string, mname string) (error, *Response) {
 
-	pmember := strings.Replace(mname, "/", "~", -1)
-	pool := strings.Replace(pname, "/", "~", -1)
+	pmember := f.escapeName(mname)
+	pool := f.escapeName(pname)
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members/" + pmember
 	res := json.RawMessage{}
@@ -446,7 +391,7 @@ func (f *Device) OfflinePoolMember(pname string, mname string) (error, *Response
 	body := LBPoolMemberState{"user-up", "user-disabled"}
 
 	// put the request
-	err, resp := f.sendRequest(u, PUT, &body, &res)
+	err, resp := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -454,10 +399,10 @@ func (f *Device) OfflinePoolMember(pname string, mname string) (error, *Response
 	}
 
 }
-func (f *Device) OfflinePoolMemberForced(pname string, mname string) (error, *Response) {
+func (f *Device) OfflinePoolMemberForced(ctx context.Context, pname string, mname string) (error, *Response) {
 
-	pmember := strings.Replace(mname, "/", "~", -1)
-	pool := strings.Replace(pname, "/", "~", -1)
+	pmember := f.escapeName(mname)
+	pool := f.escapeName(pname)
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members/" + pmember
 	res := json.RawMessage{}
@@ -470,7 +415,7 @@ func (f *Device) OfflinePoolMemberForced(pname string, mname string) (error, *Re
 	body := LBPoolMemberState{"user-down", "user-disabled"}
 
 	// put the request
-	err, resp := f.sendRequest(u, PUT, &body, &res)
+	err, resp := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -478,3 +423,124 @@ func (f *Device) OfflinePoolMemberForced(pname string, mname string) (error, *Re
 	}
 
 }
+
+// SetPoolMemberState puts a pool member into the given state and session,
+// eg. those it had before it was taken offline
+func (f *Device) SetPoolMemberState(ctx context.Context, pname string, mname string, state LBPoolMemberState) (error, *Response) {
+
+	pmember := f.escapeName(mname)
+	pool := f.escapeName(pname)
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/pool/" + pool + "/members/" + pmember
+	res := json.RawMessage{}
+
+	// put the request
+	err, resp := f.sendRequest(ctx, u, PUT, &state, &res)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, resp
+	}
+
+}
+
+// LBPoolMemberStatus is the health and load of a pool member, from its stats
+type LBPoolMemberStatus struct {
+	// eg. 10.1.1.1:80
+	Member string
+	LBObjectStatus
+	// serverside.curConns
+	Connections float64
+}
+
+// Active reports whether the monitor has the member up and it takes new connections
+func (s *LBPoolMemberStatus) Active() bool {
+	return s.AvailabilityState == "available" && s.EnabledState == "enabled"
+}
+
+// PoolMemberStatuses returns the status of every member of a pool, keyed by
+// the member name without its partition, eg. 10.1.1.1:80
+func (f *Device) PoolMemberStatuses(ctx context.Context, pname string) (error, map[string]*LBPoolMemberStatus) {
+
+	err, res := f.ShowPoolMembersStats(ctx, pname)
+	if err != nil {
+		return err, nil
+	}
+
+	statuses := make(map[string]*LBPoolMemberStatus)
+	WalkStats(res, func(s Stat) {
+		if s.Path.Member == "" {
+			return
+		}
+		status, ok := statuses[s.Path.Member]
+		if !ok {
+			status = &LBPoolMemberStatus{Member: s.Path.Member}
+			statuses[s.Path.Member] = status
+		}
+		if s.Name == "serverside.curConns" {
+			status.Connections = s.Value
+		}
+		status.set(s)
+	})
+	return nil, statuses
+
+}
+
+// PoolMemberStatus returns the status of one member of a pool
+func (f *Device) PoolMemberStatus(ctx context.Context, pname string, mname string) (error, *LBPoolMemberStatus) {
+
+	err, statuses := f.PoolMemberStatuses(ctx, pname)
+	if err != nil {
+		return err, nil
+	}
+	// members are named in stats without their partition
+	_, member := splitStatsName(strings.Replace(mname, "/", "~", -1))
+	status, ok := statuses[member]
+	if !ok {
+		return fmt.Errorf("error: no stats for pool member %s in pool %s", mname, pname), nil
+	}
+	return nil, status
+
+}
+
+// PoolMemberConnections returns the current server side connections of a pool member
+func (f *Device) PoolMemberConnections(ctx context.Context, pname string, mname string) (error, float64) {
+	err, status := f.PoolMemberStatus(ctx, pname, mname)
+	if err != nil {
+		return err, 0
+	}
+	return nil, status.Connections
+}
+
+// WaitForDrain polls the connections of a pool member every interval until
+// there are no more than threshold or ctx is done. report is called with the
+// connections each time they are polled.
+func (f *Device) WaitForDrain(ctx context.Context, pname string, mname string, threshold float64, interval time.Duration, report func(float64)) (error, float64) {
+
+	for {
+		err, conns := f.PoolMemberConnections(ctx, pname, mname)
+		if err != nil {
+			return err, conns
+		}
+		if report != nil {
+			report(conns)
+		}
+		if conns <= threshold {
+			return nil, conns
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error: gave up waiting for pool member %s to drain, %.f connections left : %s", mname, conns, ctx.Err()), conns
+		case <-time.After(interval):
+		}
+	}
+
+}
+
+// WaitForAvailable polls the status of a pool member every interval until its
+// monitor reports it available or ctx is done. report is called each time the
+// status changes.
+func (f *Device) WaitForAvailable(ctx context.Context, pname string, mname string, interval time.Duration, report func(*LBObjectStatus)) (error, *LBObjectStatus) {
+	return f.WaitFor(ctx, LBObjectRef{Kind: "poolmember", Pool: pname, Name: mname}, WaitState{Availability: "available"}, interval, report)
+}
@@ -1,6 +1,9 @@
 package f5
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 type LBProfileRef struct {
 	Link string `json:"link"`
@@ -14,12 +17,12 @@ type LBProfiles struct {
 	Items []LBProfile `json:"items"`
 }
 
-func (f *Device) ShowProfiles() (error, *LBProfiles) {
+func (f *Device) ShowProfiles(ctx context.Context) (error, *LBProfiles) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile"
 	res := LBProfiles{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -28,12 +31,17 @@ func (f *Device) ShowProfiles() (error, *LBProfiles) {
 
 }
 
-func (f *Device) ShowProfile(profile string) (error, *json.RawMessage) {
+// ProfilePaths maps the full path of every profile, of any type, to its type
+func (f *Device) ProfilePaths(ctx context.Context) (error, map[string]string) {
+	return f.listAllPaths(ctx, f.Proto+"://"+f.Hostname+"/mgmt/tm/ltm/profile")
+}
+
+func (f *Device) ShowProfile(ctx context.Context, profile string) (error, *json.RawMessage) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/" + profile
 	res := json.RawMessage{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -43,13 +51,13 @@ func (f *Device) ShowProfile(profile string) (error, *json.RawMessage) {
 }
 
 /*
-func (f *Device) ShowServerSsl(sname string) (error, *LBServerSsl) {
+func (f *Device) ShowServerSsl(ctx context.Context, sname string) (error, *LBServerSsl) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := LBServerSsl{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -58,13 +66,13 @@ func (f *Device) ShowServerSsl(sname string) (error, *LBServerSsl) {
 
 }
 
-func (f *Device) AddServerSsl(body *json.RawMessage) (error, *LBServerSsl) {
+func (f *Device) AddServerSsl(ctx context.Context, body *json.RawMessage) (error, *LBServerSsl) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl"
 	res := LBServerSsl{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -73,14 +81,14 @@ func (f *Device) AddServerSsl(body *json.RawMessage) (error, *LBServerSsl) {
 
 }
 
-func (f *Device) UpdateServerSsl(sname string, body *json.RawMessage) (error, *LBServerSsl) {
+func (f *Device) UpdateServerSsl(ctx context.Context, sname string, body *json.RawMessage) (error, *LBServerSsl) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := LBServerSsl{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -89,13 +97,13 @@ func (f *Device) UpdateServerSsl(sname string, body *json.RawMessage) (error, *L
 
 }
 
-func (f *Device) DeleteServerSsl(sname string) (error, *Response) {
+func (f *Device) DeleteServerSsl(ctx context.Context, sname string) (error, *Response) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
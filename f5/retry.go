@@ -0,0 +1,148 @@
+package f5
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetries      = 3
+	defaultRetryWait    = 500 * time.Millisecond
+	defaultRetryMaxWait = 10 * time.Second
+)
+
+// SetRetries sets how many times an idempotent request (GET, PUT or DELETE) is
+// retried after a transient failure, waiting between wait and maxWait with
+// exponential backoff in between
+func (f *Device) SetRetries(retries int, wait time.Duration, maxWait time.Duration) {
+	f.retries = retries
+	f.retryWait = wait
+	f.retryMaxWait = maxWait
+}
+
+// SetTimeout limits how long a single request may take, 0 for no limit
+func (f *Device) SetTimeout(d time.Duration) {
+	f.timeout = d
+}
+
+// SetMaxConcurrent limits how many requests can be made to the device at
+// once, 0 for no limit
+func (f *Device) SetMaxConcurrent(n int) {
+	if n > 0 {
+		f.slots = make(chan struct{}, n)
+	} else {
+		f.slots = nil
+	}
+}
+
+// SetRateLimit limits how many requests are made to the device each second,
+// 0 for no limit
+func (f *Device) SetRateLimit(perSecond float64) {
+	f.rateMutex.Lock()
+	defer f.rateMutex.Unlock()
+	if perSecond > 0 {
+		f.rateInterval = time.Duration(float64(time.Second) / perSecond)
+	} else {
+		f.rateInterval = 0
+	}
+}
+
+// idempotent methods can be safely repeated
+func idempotent(method int) bool {
+	switch method {
+	case GET, PUT, PUTR, DELETE:
+		return true
+	}
+	return false
+}
+
+// isTransient reports whether a request that failed with err is worth trying again
+func isTransient(err error) bool {
+
+	if e, ok := asError(err); ok {
+		switch e.Status {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var nerr net.Error
+	switch {
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return true
+	case errors.Is(err, context.DeadlineExceeded):
+		// the request timed out - the caller's own deadline is checked separately
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset by peer")
+
+}
+
+// backoff returns how long to wait before retry number attempt, doubling each
+// time up to the maximum, with jitter so that clients don't retry in step
+func (f *Device) backoff(attempt int) time.Duration {
+
+	d := f.retryWait
+	for i := 0; i < attempt && d < f.retryMaxWait; i++ {
+		d *= 2
+	}
+	if d > f.retryMaxWait {
+		d = f.retryMaxWait
+	}
+	if d <= 0 {
+		return 0
+	}
+	// anywhere between half and all of the backoff
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+}
+
+// acquire waits for a free request slot and the next request allowed by the
+// rate limit. The returned func releases the slot.
+func (f *Device) acquire(ctx context.Context) (error, func()) {
+
+	release := func() {}
+	if f.slots != nil {
+		select {
+		case f.slots <- struct{}{}:
+			release = func() { <-f.slots }
+		case <-ctx.Done():
+			return ctx.Err(), nil
+		}
+	}
+
+	f.rateMutex.Lock()
+	var wait time.Duration
+	if f.rateInterval > 0 {
+		now := time.Now()
+		if f.rateNext.Before(now) {
+			f.rateNext = now
+		}
+		wait = f.rateNext.Sub(now)
+		f.rateNext = f.rateNext.Add(f.rateInterval)
+	}
+	f.rateMutex.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			release()
+			return ctx.Err(), nil
+		}
+	}
+	return nil, release
+
+}
@@ -0,0 +1,168 @@
+package f5
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// LBRuleEventProfile is how an iRule has run for one of its events
+type LBRuleEventProfile struct {
+	// eg. HTTP_REQUEST
+	Event      string
+	Executions float64
+	Failures   float64
+	Aborts     float64
+	AvgCycles  float64
+	MaxCycles  float64
+	MinCycles  float64
+}
+
+// TotalCycles is the CPU cycles the event has used since the stats were reset
+func (e *LBRuleEventProfile) TotalCycles() float64 {
+	return e.AvgCycles * e.Executions
+}
+
+// set fills in the profile from a statistic, if it is part of it
+func (e *LBRuleEventProfile) set(stat Stat) {
+	switch stat.Name {
+	case "eventType":
+		if e.Event == "" {
+			e.Event = stat.Description
+		}
+	case "totalExecutions":
+		e.Executions = stat.Value
+	case "failures":
+		e.Failures = stat.Value
+	case "aborts":
+		e.Aborts = stat.Value
+	case "avgCycles":
+		e.AvgCycles = stat.Value
+	case "maxCycles":
+		e.MaxCycles = stat.Value
+	case "minCycles":
+		e.MinCycles = stat.Value
+	}
+}
+
+// LBRuleProfile is how an iRule has run across its events, with the virtuals
+// it is attached to
+type LBRuleProfile struct {
+	FullPath string
+	Virtuals []string
+	Events   []*LBRuleEventProfile
+}
+
+func (p *LBRuleProfile) sum(value func(*LBRuleEventProfile) float64) float64 {
+	total := 0.0
+	for _, e := range p.Events {
+		total += value(e)
+	}
+	return total
+}
+
+func (p *LBRuleProfile) Executions() float64 {
+	return p.sum(func(e *LBRuleEventProfile) float64 { return e.Executions })
+}
+
+func (p *LBRuleProfile) Failures() float64 {
+	return p.sum(func(e *LBRuleEventProfile) float64 { return e.Failures })
+}
+
+func (p *LBRuleProfile) Aborts() float64 {
+	return p.sum(func(e *LBRuleEventProfile) float64 { return e.Aborts })
+}
+
+func (p *LBRuleProfile) TotalCycles() float64 {
+	return p.sum(func(e *LBRuleEventProfile) float64 { return e.TotalCycles() })
+}
+
+// Unused reports whether the rule is attached to no virtual or has never run
+func (p *LBRuleProfile) Unused() bool {
+	return len(p.Virtuals) == 0 || p.Executions() == 0
+}
+
+// ruleFullPath qualifies a rule name given by a virtual, which older versions
+// give without a partition
+func ruleFullPath(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "/Common/" + name
+}
+
+// RuleProfiles combines the stats of every iRule with the virtuals that use
+// it, busiest first by the CPU cycles used
+func (f *Device) RuleProfiles(ctx context.Context) (error, []*LBRuleProfile) {
+
+	err, rules := f.ShowRules(ctx, func(o *ListOptions) {
+		o.Fields = []string{"fullPath"}
+	})
+	if err != nil {
+		return err, nil
+	}
+	err, virtuals := f.ShowVirtuals(ctx, func(o *ListOptions) {
+		o.Fields = []string{"fullPath", "rules"}
+	})
+	if err != nil {
+		return err, nil
+	}
+	err, stats := f.ShowAllRuleStats(ctx)
+	if err != nil {
+		return err, nil
+	}
+
+	profiles := make([]*LBRuleProfile, 0, len(rules.Items))
+	byPath := make(map[string]*LBRuleProfile)
+	profile := func(fullPath string) *LBRuleProfile {
+		p, ok := byPath[fullPath]
+		if !ok {
+			p = &LBRuleProfile{FullPath: fullPath}
+			byPath[fullPath] = p
+			profiles = append(profiles, p)
+		}
+		return p
+	}
+	for _, r := range rules.Items {
+		profile(r.FullPath)
+	}
+	for _, v := range virtuals.Items {
+		for _, r := range v.Rules {
+			p := profile(ruleFullPath(r))
+			p.Virtuals = append(p.Virtuals, v.FullPath)
+		}
+	}
+
+	// stats are kept for each event of a rule, eg. ~Common~redirect:HTTP_REQUEST
+	events := make(map[string]*LBRuleEventProfile)
+	WalkStats(stats, func(s Stat) {
+		if s.Path.Kind != "rule" || s.Path.Object == "" {
+			return
+		}
+		fullPath := "/" + s.Path.Partition + "/" + s.Path.Object
+		key := fullPath + ":" + s.Path.Member
+		e, ok := events[key]
+		if !ok {
+			e = &LBRuleEventProfile{Event: s.Path.Member}
+			events[key] = e
+			p := profile(fullPath)
+			p.Events = append(p.Events, e)
+		}
+		e.set(s)
+	})
+
+	for _, p := range profiles {
+		sort.Strings(p.Virtuals)
+		sort.Slice(p.Events, func(i, j int) bool {
+			return p.Events[i].TotalCycles() > p.Events[j].TotalCycles()
+		})
+	}
+	sort.SliceStable(profiles, func(i, j int) bool {
+		if profiles[i].TotalCycles() != profiles[j].TotalCycles() {
+			return profiles[i].TotalCycles() > profiles[j].TotalCycles()
+		}
+		return profiles[i].FullPath < profiles[j].FullPath
+	})
+	return nil, profiles
+
+}
@@ -0,0 +1,118 @@
+package f5
+
+import (
+	"net"
+	"strings"
+)
+
+// LBRuleRefs are the objects the TCL of an iRule names. Names are as given in
+// the rule, so may have no partition.
+type LBRuleRefs struct {
+	// eg. pool web_pool
+	Pools []string
+	// addresses given to node, eg. node 10.1.1.1 80
+	Nodes []string
+	// data groups, eg. class match [IP::client_addr] equals blocked
+	Classes []string
+	// set when the rule picks a pool or node at run time, eg. pool $name, so
+	// any pool or node could be used
+	DynamicPools bool
+	DynamicNodes bool
+}
+
+// the position of the class name among the arguments of each class
+// subcommand, after any options
+var classNameArg = map[string]int{
+	"match":       2,
+	"search":      0,
+	"lookup":      1,
+	"element":     1,
+	"type":        0,
+	"exists":      0,
+	"size":        0,
+	"names":       0,
+	"get":         0,
+	"startsearch": 0,
+}
+
+// literal reports whether a word is the same whenever the rule runs
+func literal(w tclWord) bool {
+	return w.braced || (len(w.subs) == 0 && !strings.Contains(w.text, "$"))
+}
+
+// RuleRefs finds the pools, nodes and data groups the TCL of an iRule refers
+// to. Anything in braces is searched, as it may be a script.
+func RuleRefs(tcl string) *LBRuleRefs {
+	r := &LBRuleRefs{}
+	cmds, _ := parseTcl(tcl, 1)
+	r.script(cmds)
+	return r
+}
+
+func (r *LBRuleRefs) script(cmds []tclCommand) {
+	for _, cmd := range cmds {
+		r.command(cmd)
+	}
+}
+
+func (r *LBRuleRefs) command(cmd tclCommand) {
+
+	for _, w := range cmd.words {
+		for _, sub := range w.subs {
+			r.script(sub)
+		}
+		if w.braced {
+			cmds, _ := parseTcl(w.text, w.textLine)
+			r.script(cmds)
+		}
+	}
+
+	args := cmd.words[1:]
+	if len(args) == 0 {
+		return
+	}
+	switch cmd.words[0].text {
+	case "pool":
+		if literal(args[0]) {
+			r.Pools = append(r.Pools, args[0].text)
+		} else {
+			r.DynamicPools = true
+		}
+	case "node":
+		if literal(args[0]) {
+			r.Nodes = append(r.Nodes, nodeAddress(args[0].text))
+		} else {
+			r.DynamicNodes = true
+		}
+	case "class":
+		pos, ok := classNameArg[args[0].text]
+		if !ok {
+			return
+		}
+		rest := args[1:]
+		for len(rest) > 0 && strings.HasPrefix(rest[0].text, "-") && !rest[0].braced {
+			done := rest[0].text == "--"
+			rest = rest[1:]
+			if done {
+				break
+			}
+		}
+		if pos < len(rest) && literal(rest[pos]) {
+			r.Classes = append(r.Classes, rest[pos].text)
+		}
+	case "matchclass":
+		if len(args) == 3 && literal(args[2]) {
+			r.Classes = append(r.Classes, args[2].text)
+		}
+	}
+
+}
+
+// nodeAddress strips any port from the address given to node, eg.
+// 10.1.1.1:80 or [2001:db8::1]:80
+func nodeAddress(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
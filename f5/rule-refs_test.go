@@ -0,0 +1,45 @@
+package f5
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleRefs(t *testing.T) {
+
+	tcl := `when HTTP_REQUEST {
+  if { [class match [IP::client_addr] equals blocked] } {
+    drop
+  } elseif { [HTTP::uri] starts_with "/api" } {
+    pool /Common/api_pool
+  } else {
+    switch [HTTP::host] {
+      "old.example.com" { node 10.1.1.9:80 }
+      default { pool web_pool member 10.1.1.1 80 }
+    }
+  }
+  set target [class match -value -- [HTTP::host] equals hosts]
+}`
+	got := RuleRefs(tcl)
+	want := &LBRuleRefs{
+		Pools:   []string{"/Common/api_pool", "web_pool"},
+		Nodes:   []string{"10.1.1.9"},
+		Classes: []string{"blocked", "hosts"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+}
+
+func TestRuleRefsDynamic(t *testing.T) {
+
+	got := RuleRefs("when HTTP_REQUEST {\n  pool [class match -value [HTTP::host] equals pools]\n  node $backend\n}")
+	if !got.DynamicPools || !got.DynamicNodes || len(got.Pools) != 0 || len(got.Nodes) != 0 {
+		t.Errorf("got %+v, want a dynamic pool and node", got)
+	}
+	if want := []string{"pools"}; !reflect.DeepEqual(got.Classes, want) {
+		t.Errorf("got classes %v, want %v", got.Classes, want)
+	}
+
+}
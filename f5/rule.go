@@ -2,8 +2,12 @@ package f5
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 type LBRawValues struct {
@@ -23,50 +27,40 @@ type LBRules struct {
 	Items []LBRule `json:"items"`
 }
 
-type LBRuleStatsDescription struct {
-	Description string `json:"description"`
+// LBRuleBody is what is sent to create or update an iRule from its TCL
+type LBRuleBody struct {
+	Name         string `json:"name"`
+	Partition    string `json:"partition,omitempty"`
+	ApiAnonymous string `json:"apiAnonymous"`
 }
 
-type LBRuleStatsValue struct {
-	Value int `json:"value"`
-}
+// RuleBody builds the body of an iRule named eg. /DMZ/redirect from its TCL
+func (f *Device) RuleBody(rname string, tcl []byte) (error, *json.RawMessage) {
 
-type LBRuleStatsInnerEntries struct {
-	Aborts          LBStatsValue           `json:"aborts"`
-	AvgCycles       LBStatsValue           `json:"avgCycles"`
-	EventType       LBRuleStatsDescription `json:"eventType"`
-	Failures        LBStatsValue           `json:"failures"`
-	MaxCycles       LBStatsValue           `json:"maxCycles"`
-	MinCycles       LBStatsValue           `json:"minCycles"`
-	TmName          LBRuleStatsDescription `json:"tmName"`
-	Priority        LBStatsValue           `json:"priority"`
-	TotalExecutions LBStatsValue           `json:"totalExecutions"`
-}
-
-type LBRuleStatsNestedStats struct {
-	Kind     string                  `json:"kind"`
-	SelfLink string                  `json:"selfLink"`
-	Entries  LBRuleStatsInnerEntries `json:"entries"`
-}
+	body := LBRuleBody{Name: rname, ApiAnonymous: string(tcl)}
+	parts := strings.Split(strings.Trim(rname, "/"), "/")
+	switch {
+	case strings.HasPrefix(rname, "/") && len(parts) == 2:
+		body.Partition, body.Name = parts[0], parts[1]
+	case !strings.HasPrefix(rname, "/") && f.Partition != "":
+		body.Partition = f.Partition
+	}
 
-type LBRuleURLKey struct {
-	NestedStats LBRuleStatsNestedStats `json:"nestedStats"`
-}
-type LBRuleStatsOuterEntries map[string]LBRuleURLKey
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err, nil
+	}
+	raw := json.RawMessage(b)
+	return nil, &raw
 
-type LBRuleStats struct {
-	Kind       string                  `json:"kind"`
-	Generation int                     `json:"generation"`
-	SelfLink   string                  `json:"selfLink"`
-	Entries    LBRuleStatsOuterEntries `json:"entries"`
 }
 
-func (f *Device) ShowRules() (error, *LBRules) {
+func (f *Device) ShowRules(ctx context.Context, opts ...func(*ListOptions)) (error, *LBRules) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule"
 	res := LBRules{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -75,13 +69,13 @@ func (f *Device) ShowRules() (error, *LBRules) {
 
 }
 
-func (f *Device) ShowRule(rname string) (error, *LBRule) {
+func (f *Device) ShowRule(ctx context.Context, rname string) (error, *LBRule) {
 
-	rule := strings.Replace(rname, "/", "~", -1)
+	rule := f.escapeName(rname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule
 	res := LBRule{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -90,13 +84,13 @@ func (f *Device) ShowRule(rname string) (error, *LBRule) {
 
 }
 
-func (f *Device) ShowRuleStats(rname string) (error, *LBObjectStats) {
+func (f *Device) ShowRuleStats(ctx context.Context, rname string) (error, *LBStatsTree) {
 
-	rule := strings.Replace(rname, "/", "~", -1)
+	rule := f.escapeName(rname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule + "/stats"
-	res := LBObjectStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -105,12 +99,12 @@ func (f *Device) ShowRuleStats(rname string) (error, *LBObjectStats) {
 
 }
 
-func (f *Device) ShowAllRuleStats() (error, *LBRuleStats) {
+func (f *Device) ShowAllRuleStats(ctx context.Context) (error, *LBStatsTree) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/stats"
-	res := LBRuleStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -119,13 +113,13 @@ func (f *Device) ShowAllRuleStats() (error, *LBRuleStats) {
 
 }
 
-func (f *Device) AddRule(body *json.RawMessage) (error, *LBRule) {
+func (f *Device) AddRule(ctx context.Context, body *json.RawMessage) (error, *LBRule) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule"
 	res := LBRule{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -134,13 +128,13 @@ func (f *Device) AddRule(body *json.RawMessage) (error, *LBRule) {
 
 }
 
-func (f *Device) AddRuleRaw(body *bytes.Buffer) (error, *LBRule) {
+func (f *Device) AddRuleRaw(ctx context.Context, body *bytes.Buffer) (error, *LBRule) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule"
 	res := LBRule{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POSTR, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POSTR, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -149,14 +143,23 @@ func (f *Device) AddRuleRaw(body *bytes.Buffer) (error, *LBRule) {
 
 }
 
-func (f *Device) UpdateRule(rname string, body *json.RawMessage) (error, *LBRule) {
+// AddRuleTcl creates an iRule named eg. /DMZ/redirect from its TCL
+func (f *Device) AddRuleTcl(ctx context.Context, rname string, tcl []byte) (error, *LBRule) {
+	err, body := f.RuleBody(rname, tcl)
+	if err != nil {
+		return err, nil
+	}
+	return f.AddRule(ctx, body)
+}
+
+func (f *Device) UpdateRule(ctx context.Context, rname string, body *json.RawMessage) (error, *LBRule) {
 
-	rule := strings.Replace(rname, "/", "~", -1)
+	rule := f.escapeName(rname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule
 	res := LBRule{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -165,14 +168,23 @@ func (f *Device) UpdateRule(rname string, body *json.RawMessage) (error, *LBRule
 
 }
 
-func (f *Device) UpdateRuleRaw(rname string, body *bytes.Buffer) (error, *LBRule) {
+// UpdateRuleTcl replaces the TCL of an iRule
+func (f *Device) UpdateRuleTcl(ctx context.Context, rname string, tcl []byte) (error, *LBRule) {
+	err, body := f.RuleBody(rname, tcl)
+	if err != nil {
+		return err, nil
+	}
+	return f.UpdateRule(ctx, rname, body)
+}
+
+func (f *Device) UpdateRuleRaw(ctx context.Context, rname string, body *bytes.Buffer) (error, *LBRule) {
 
-	rule := strings.Replace(rname, "/", "~", -1)
+	rule := f.escapeName(rname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule
 	res := LBRule{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUTR, body, &res)
+	err, _ := f.sendRequest(ctx, u, PUTR, body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -181,13 +193,41 @@ func (f *Device) UpdateRuleRaw(rname string, body *bytes.Buffer) (error, *LBRule
 
 }
 
-func (f *Device) DeleteRule(rname string) (error, *Response) {
+// PatchRule replaces the TCL of an iRule if it has changed. The TCL is a
+// single string so there is nothing to merge, whatever the merge strategy.
+func (f *Device) PatchRule(ctx context.Context, rname string, patch *LBRule) (error, *LBRule) {
+
+	rule := f.escapeName(rname)
+	url := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule
+
+	err, existing := f.ShowRule(ctx, rname)
+	if err != nil {
+		return err, nil
+	}
+
+	if f.DryRun() {
+		fmt.Printf("Patching: %s\nPatch Diff:\n%s\n", url, cmp.Diff(existing.ApiAnonymous, patch.ApiAnonymous))
+		return nil, patch
+	}
+	if existing.ApiAnonymous == patch.ApiAnonymous {
+		return nil, existing
+	}
+	body := LBRuleBody{Name: existing.Name, Partition: existing.Partition, ApiAnonymous: patch.ApiAnonymous}
+	err, _ = f.sendRequest(ctx, url, PATCH, &body, existing)
+	if err != nil {
+		return err, nil
+	}
+	return nil, existing
+
+}
+
+func (f *Device) DeleteRule(ctx context.Context, rname string) (error, *Response) {
 
-	rule := strings.Replace(rname, "/", "~", -1)
+	rule := f.escapeName(rname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/rule/" + rule
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -1,9 +1,9 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -78,12 +78,12 @@ type LBServerSsls struct {
 	Items []LBServerSsl `json:"items"`
 }
 
-func (f *Device) ShowServerSsls() (error, *LBServerSsls) {
+func (f *Device) ShowServerSsls(ctx context.Context, opts ...func(*ListOptions)) (error, *LBServerSsls) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl"
 	res := LBServerSsls{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -92,13 +92,13 @@ func (f *Device) ShowServerSsls() (error, *LBServerSsls) {
 
 }
 
-func (f *Device) ShowServerSsl(sname string) (error, *LBServerSsl) {
+func (f *Device) ShowServerSsl(ctx context.Context, sname string) (error, *LBServerSsl) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := LBServerSsl{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -107,13 +107,13 @@ func (f *Device) ShowServerSsl(sname string) (error, *LBServerSsl) {
 
 }
 
-func (f *Device) AddServerSsl(body *json.RawMessage) (error, *LBServerSsl) {
+func (f *Device) AddServerSsl(ctx context.Context, body *json.RawMessage) (error, *LBServerSsl) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl"
 	res := LBServerSsl{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, &body, &res)
+	err, _ := f.sendRequest(ctx, u, POST, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -122,14 +122,14 @@ func (f *Device) AddServerSsl(body *json.RawMessage) (error, *LBServerSsl) {
 
 }
 
-func (f *Device) UpdateServerSsl(sname string, body *json.RawMessage) (error, *LBServerSsl) {
+func (f *Device) UpdateServerSsl(ctx context.Context, sname string, body *json.RawMessage) (error, *LBServerSsl) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := LBServerSsl{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -138,8 +138,8 @@ func (f *Device) UpdateServerSsl(sname string, body *json.RawMessage) (error, *L
 
 }
 
-func (f *Device) PatchServerSsl(name string, patch *LBServerSsl) (error, *LBServerSsl) {
-	name = strings.Replace(name, "/", "~", -1)
+func (f *Device) PatchServerSsl(ctx context.Context, name string, patch *LBServerSsl) (error, *LBServerSsl) {
+	name = f.escapeName(name)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/profile/server-ssl/%s", f.Proto, f.Hostname, name)
 	existing := &LBServerSsl{}
 	var err error
@@ -148,7 +148,7 @@ func (f *Device) PatchServerSsl(name string, patch *LBServerSsl) (error, *LBServ
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowServerSsl(name)
+		err, existing = f.ShowServerSsl(ctx, name)
 		if err != nil {
 			return err, nil
 		}
@@ -171,7 +171,7 @@ func (f *Device) PatchServerSsl(name string, patch *LBServerSsl) (error, *LBServ
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -181,13 +181,13 @@ func (f *Device) PatchServerSsl(name string, patch *LBServerSsl) (error, *LBServ
 	}
 }
 
-func (f *Device) DeleteServerSsl(sname string) (error, *Response) {
+func (f *Device) DeleteServerSsl(ctx context.Context, sname string) (error, *Response) {
 
-	server := strings.Replace(sname, "/", "~", -1)
+	server := f.escapeName(sname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/profile/server-ssl/" + server
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -1,26 +1,55 @@
 package f5
 
 import (
+	"context"
 	"fmt"
-	"github.com/fatih/structs"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
 
-type LBStatsValue struct {
-	// use a wide float - some values can be BIG
-	Value float64 `json:"value"`
+// LBStatsTree is any stats response. Each entry is either a statistic, with a
+// value or a description, or nested stats - of another object when keyed by
+// its url, otherwise a group of statistics, eg.
+// {"entries":{"https://localhost/mgmt/tm/ltm/pool/~Common~web/stats":{"nestedStats":{"entries":{"curSessions":{"value":0}, ...}}}}}
+type LBStatsTree struct {
+	Kind     string                  `json:"kind,omitempty"`
+	SelfLink string                  `json:"selfLink,omitempty"`
+	Entries  map[string]LBStatsEntry `json:"entries"`
 }
 
-type LBStatsDescription struct {
-	Description string `json:"description"`
+type LBStatsEntry struct {
+	// use a wide float - some values can be BIG
+	Value       *float64     `json:"value,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	NestedStats *LBStatsTree `json:"nestedStats,omitempty"`
 }
 
-type LBObjectStatsMap map[string]LBStatsValue
+// StatsPath is the object statistics belong to, parsed from its url, eg.
+// /mgmt/tm/ltm/pool/~Common~web/members/~Common~10.1.1.1:80/stats
+type StatsPath struct {
+	// eg. pool
+	Kind      string
+	Partition string
+	// the object name, including any folder, eg. app/web
+	Object string
+	// eg. members
+	Subcollection string
+	// eg. 10.1.1.1:80, or the event of an iRule
+	Member string
+}
 
-type LBObjectStats struct {
-	Entries LBObjectStatsMap `json:"entries"`
+// Stat is a single statistic found in a stats tree
+type Stat struct {
+	Path StatsPath
+	// eg. serverside.curConns
+	Name        string
+	Value       float64
+	Description string
+	// false when there is only a description, eg. status.availabilityState
+	Numeric bool
 }
 
 // Metric is a struct that defines the relevant properties of a graphite metric
@@ -47,610 +76,223 @@ func (dp GraphiteDataPoint) String() string {
 	)
 }
 
-func (f *Device) Stats() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 16384)
-	err, pools := f.StatsPools()
-	if err != nil {
-		return err, nil
+// splitStatsName splits ~partition~folder~name into partition and name
+func splitStatsName(s string) (string, string) {
+	parts := strings.Split(strings.Trim(s, "~"), "~")
+	if len(parts) == 1 {
+		return "", parts[0]
 	}
-	data = append(data, pools...)
+	return parts[0], strings.Join(parts[1:], "/")
+}
 
-	err, virtuals := f.StatsVirtuals()
-	if err != nil {
-		return err, nil
-	}
-	data = append(data, virtuals...)
+// ParseStatsPath finds the object in the url of its stats
+func ParseStatsPath(link string) (error, StatsPath) {
 
-	err, nodes := f.StatsNodes()
+	p := StatsPath{}
+	u, err := url.Parse(link)
 	if err != nil {
-		return err, nil
+		return err, p
 	}
-	data = append(data, nodes...)
-
-	err, rules := f.StatsRules()
-	if err != nil {
-		return err, nil
-	}
-	data = append(data, rules...)
-	return nil, data
-}
-
-func (f *Device) StatsPool(pname string) (error, []GraphiteDataPoint) {
-
-	pool := strings.Replace(pname, "/", "~", -1)
-	splitter := func(c rune) bool {
-		// split if "/" or "~"
-		return c == '\u002f' || c == '\u007e'
 
+	segments := make([]string, 0)
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
 	}
-	fields := strings.FieldsFunc(pool, splitter)
-	partition := fields[0]
-	poolname := fields[1]
-	if len(partition) < 1 || len(poolname) < 1 {
-		return fmt.Errorf("error: cannot parse partition and poolname for given pool: %s", pname), nil
+	if len(segments) > 0 && segments[len(segments)-1] == "stats" {
+		segments = segments[:len(segments)-1]
 	}
-	prefix := f.StatsPathPrefix + partition + ".pool."
-
-	data := make([]GraphiteDataPoint, 0, 1024)
-	start := time.Now()
-	timestamp := start.Unix()
 
-	err, res := f.ShowPoolStats(pool)
-	if err != nil {
-		return err, nil
-	} else {
-
-		for key := range res.Entries {
-
-			skey := prefix + poolname + "." + key
-			var v interface{}
-			v = res.Entries[key]
-
-			if value, ok := v.(LBStatsValue); ok {
-				if value.Value > 0 {
-					// only print value if it is greater than zero
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				} else if f.StatsShowZeroes {
-					// except if I really want it
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				}
+	for i := 0; i+2 < len(segments); i++ {
+		if segments[i] == "mgmt" && segments[i+1] == "tm" && segments[i+2] == "sys" {
+			p = parseSysStatsPath(segments[i+3:])
+			if p.Object == "" {
+				return fmt.Errorf("error: cannot find an object in stats url %s", link), p
 			}
-
+			return nil, p
 		}
+	}
 
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
+	// the object is the first segment with a partition
+	i := 0
+	for i < len(segments) && !strings.HasPrefix(segments[i], "~") {
+		i++
+	}
+	if i == 0 || i == len(segments) {
+		return fmt.Errorf("error: cannot find an object in stats url %s", link), p
+	}
+	p.Kind = segments[i-1]
+	p.Partition, p.Object = splitStatsName(segments[i])
+	if p.Kind == "rule" {
+		// iRule stats are kept for each event, eg. ~Common~redirect:HTTP_REQUEST
+		if j := strings.LastIndex(p.Object, ":"); j >= 0 {
+			p.Object, p.Member = p.Object[:j], p.Object[j+1:]
+		}
+	}
 
+	// newer versions repeat the object name, eg. /pool/~Common~web/~Common~web/stats
+	rest := segments[i+1:]
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "~") {
+		rest = rest[1:]
 	}
+	if len(rest) > 0 {
+		p.Subcollection = rest[0]
+	}
+	if len(rest) > 1 {
+		_, p.Member = splitStatsName(strings.Join(rest[1:], "~"))
+	}
+	return nil, p
 
 }
 
-func (f *Device) StatsPools() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-	err, res := f.ShowAllPoolStats()
-	if err != nil {
-		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
-
-		}
+// isStatsLink reports whether a key of a stats tree is the url of an object
+func isStatsLink(key string) bool {
+	return strings.Contains(key, "/mgmt/tm/")
+}
 
-		for surl, stats := range res.Entries {
+func walkStats(tree *LBStatsTree, path StatsPath, prefix string, visit func(Stat)) {
 
-			fields := strings.FieldsFunc(surl, splitter)
-			partition := fields[6]
-			poolname := fields[7]
-			if len(partition) < 1 || len(poolname) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse partition and poolname for pool given url: %s", surl)
-				continue
+	for key, entry := range tree.Entries {
+		switch {
+		case entry.NestedStats != nil && isStatsLink(key):
+			link := entry.NestedStats.SelfLink
+			if link == "" {
+				link = key
 			}
-
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-
-			prefix := f.StatsPathPrefix + partition + ".pool."
-
-			for key := range fnames {
-
-				skey := prefix + poolname + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
+			err, p := ParseStatsPath(link)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warn: cannot parse object for stats given url: %s\n", link)
+				continue
 			}
-
+			walkStats(entry.NestedStats, p, "", visit)
+		case entry.NestedStats != nil:
+			walkStats(entry.NestedStats, path, prefix+key+".", visit)
+		case entry.Value != nil:
+			visit(Stat{Path: path, Name: prefix + key, Value: *entry.Value, Numeric: true})
+		case entry.Description != nil:
+			visit(Stat{Path: path, Name: prefix + key, Description: *entry.Description})
 		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
 	}
 
 }
 
-func (f *Device) StatsPoolMembers(pname string) (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-
-	pool := strings.Replace(pname, "/", "~", -1)
-	splitter := func(c rune) bool {
-		// split if "/" or "~"
-		return c == '\u002f' || c == '\u007e'
-
-	}
-	fields := strings.FieldsFunc(pool, splitter)
-	partition := fields[0]
-	poolname := fields[1]
-	if len(partition) < 1 || len(poolname) < 1 {
-		return fmt.Errorf("error: cannot parse partition and poolname for given pool: %s", pname), nil
+// WalkStats calls visit with every statistic in a stats tree, whatever the
+// object and however deeply it is nested
+func WalkStats(tree *LBStatsTree, visit func(Stat)) {
+	path := StatsPath{}
+	if tree.SelfLink != "" {
+		// statistics at the top belong to the object the tree is for
+		_, path = ParseStatsPath(tree.SelfLink)
 	}
+	walkStats(tree, path, "", visit)
+}
 
-	prefix := f.StatsPathPrefix + partition + ".pool."
-	err, res := f.ShowPoolMembersStats(pool)
-	if err != nil {
-		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
+// metrics returns the numeric statistics in a stats tree
+func (f *Device) metrics(tree *LBStatsTree) []Metric {
 
+	data := make([]Metric, 0, 1024)
+	sampled := time.Now()
+	WalkStats(tree, func(s Stat) {
+		if !s.Numeric || s.Path.Object == "" {
+			return
 		}
-
-		for surl, stats := range res.Entries {
-
-			fields := strings.FieldsFunc(surl, splitter)
-			poolname := fields[7]
-			pmember := fields[10]
-			if len(partition) < 1 || len(poolname) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse poolname and poolmember for pool given url: %s", surl)
-				continue
-			}
-
-			poolmember := strings.Replace(pmember, ":", "-", -1)
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-
-			for key := range fnames {
-
-				skey := prefix + poolname + ".poolmember." + poolmember + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
-			}
-
+		kind := s.Path.Kind
+		if kind == "pool" && s.Path.Subcollection == "members" {
+			kind = "poolmember"
 		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
-
-	}
+		data = f.appendMetric(data, newMetric(kind, s.Path.Partition, s.Path.Object, s.Path.Member, s.Name, s.Value, sampled))
+	})
+	return data
 
 }
 
-func (f *Device) StatsCommonPoolMembers() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-
-	err, res := f.ShowAllPoolMembersStats()
+func (f *Device) StatsPool(ctx context.Context, pname string) (error, []Metric) {
+	err, res := f.ShowPoolStats(ctx, pname)
 	if err != nil {
 		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
-
-		}
-
-		for surl, stats := range res.Entries {
-
-			fields := strings.FieldsFunc(surl, splitter)
-			partition := fields[6]
-			poolname := fields[7]
-			pmember := fields[10]
-			if len(partition) < 1 || len(poolname) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse poolname and poolmember for pool given url: %s", surl)
-				continue
-			}
-
-			poolmember := strings.Replace(pmember, ":", "-", -1)
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-			prefix := f.StatsPathPrefix + partition + ".pool."
-
-			for key := range fnames {
-
-				skey := prefix + poolname + ".poolmember." + poolmember + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
-			}
-
-		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
-
 	}
-
+	return nil, f.metrics(res)
 }
 
-func (f *Device) StatsNode(nname string) (error, []GraphiteDataPoint) {
-
-	node := strings.Replace(nname, "/", "~", -1)
-	splitter := func(c rune) bool {
-		// split if "/" or "~"
-		return c == '\u002f' || c == '\u007e'
-
-	}
-	fields := strings.FieldsFunc(node, splitter)
-	partition := fields[0]
-	nodename := fields[1]
-	if len(partition) < 1 || len(nodename) < 1 {
-		return fmt.Errorf("error: cannot parse partition and nodename for given node: %s", nname), nil
+func (f *Device) StatsPools(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowAllPoolStats(ctx)
+	if err != nil {
+		return err, nil
 	}
-	prefix := f.StatsPathPrefix + partition + ".node."
-
-	data := make([]GraphiteDataPoint, 0, 1024)
-	start := time.Now()
-	timestamp := start.Unix()
+	return nil, f.metrics(res)
+}
 
-	err, res := f.ShowNodeStats(node)
+func (f *Device) StatsPoolMembers(ctx context.Context, pname string) (error, []Metric) {
+	err, res := f.ShowPoolMembersStats(ctx, pname)
 	if err != nil {
 		return err, nil
-	} else {
-
-		for key := range res.Entries {
-
-			skey := prefix + nodename + "." + key
-			var v interface{}
-			v = res.Entries[key]
-
-			// only print if it is an object of LBStatsValue
-			if value, ok := v.(LBStatsValue); ok {
-				if value.Value > 0 {
-					// only print value if it is greater than zero
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				} else if f.StatsShowZeroes {
-					// except if I really want it
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				}
-			}
-
-		}
-
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
-
 	}
-
+	return nil, f.metrics(res)
 }
 
-func (f *Device) StatsNodes() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-	err, res := f.ShowAllNodeStats()
+// StatsCommonPoolMembers fetches the members of every pool in one request,
+// grouped by pool
+func (f *Device) StatsCommonPoolMembers(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowAllPoolMembersStats(ctx)
 	if err != nil {
 		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
-
-		}
-
-		for surl, stats := range res.Entries {
-
-			fields := strings.FieldsFunc(surl, splitter)
-			partition := fields[6]
-			nodename := fields[7]
-			if len(partition) < 1 || len(nodename) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse partition and nodename for node given url: %s", surl)
-				continue
-			}
-
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-
-			prefix := f.StatsPathPrefix + partition + ".node."
-
-			for key := range fnames {
-
-				skey := prefix + nodename + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
-			}
-
-		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
 	}
-
+	data := f.metrics(res)
+	sort.SliceStable(data, func(i, j int) bool {
+		a, b := data[i], data[j]
+		if a.Partition+"/"+a.Object != b.Partition+"/"+b.Object {
+			return a.Partition+"/"+a.Object < b.Partition+"/"+b.Object
+		}
+		return a.Member < b.Member
+	})
+	return nil, data
 }
 
-func (f *Device) StatsVirtual(vname string) (error, []GraphiteDataPoint) {
-
-	virtual := strings.Replace(vname, "/", "~", -1)
-	splitter := func(c rune) bool {
-		// split if "/" or "~"
-		return c == '\u002f' || c == '\u007e'
-
-	}
-	fields := strings.FieldsFunc(virtual, splitter)
-	partition := fields[0]
-	virtualname := fields[1]
-	if len(partition) < 1 || len(virtualname) < 1 {
-		return fmt.Errorf("error: cannot parse partition and virtualname for given pool: %s", vname), nil
+func (f *Device) StatsNode(ctx context.Context, nname string) (error, []Metric) {
+	err, res := f.ShowNodeStats(ctx, nname)
+	if err != nil {
+		return err, nil
 	}
-	prefix := f.StatsPathPrefix + partition + ".virtual."
-
-	data := make([]GraphiteDataPoint, 0, 1024)
-	start := time.Now()
-	timestamp := start.Unix()
+	return nil, f.metrics(res)
+}
 
-	err, res := f.ShowVirtualStats(virtual)
+func (f *Device) StatsNodes(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowAllNodeStats(ctx)
 	if err != nil {
 		return err, nil
-	} else {
-
-		for key := range res.Entries {
-
-			skey := prefix + virtualname + "." + key
-			var v interface{}
-			v = res.Entries[key]
-
-			if value, ok := v.(LBStatsValue); ok {
-				if value.Value > 0 {
-					// only print value if it is greater than zero
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				} else if f.StatsShowZeroes {
-					// except if I really want it
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				}
-			}
-
-		}
-
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
-
 	}
-
+	return nil, f.metrics(res)
 }
 
-func (f *Device) StatsVirtuals() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-	err, res := f.ShowAllVirtualStats()
+func (f *Device) StatsVirtual(ctx context.Context, vname string) (error, []Metric) {
+	err, res := f.ShowVirtualStats(ctx, vname)
 	if err != nil {
 		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
-
-		}
-
-		for surl, stats := range res.Entries {
-
-			fields := strings.FieldsFunc(surl, splitter)
-			partition := fields[6]
-			virtualname := fields[7]
-			if len(partition) < 1 || len(virtualname) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse partition and virtualname for virtual given url: %s", surl)
-				continue
-			}
-
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-
-			prefix := f.StatsPathPrefix + partition + ".virtual."
-
-			for key := range fnames {
-
-				skey := prefix + virtualname + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
-			}
-
-		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
 	}
-
+	return nil, f.metrics(res)
 }
 
-func (f *Device) StatsRule(rname string) (error, []GraphiteDataPoint) {
-
-	rule := strings.Replace(rname, "/", "~", -1)
-	splitter := func(c rune) bool {
-		// split if "/" or "~"
-		return c == '\u002f' || c == '\u007e'
-
-	}
-	fields := strings.FieldsFunc(rule, splitter)
-	partition := fields[0]
-	rulename := fields[1]
-	if len(partition) < 1 || len(rulename) < 1 {
-		return fmt.Errorf("error: cannot parse partition and rulename for given rule: %s", rname), nil
+func (f *Device) StatsVirtuals(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowAllVirtualStats(ctx)
+	if err != nil {
+		return err, nil
 	}
-	prefix := f.StatsPathPrefix + partition + ".rule."
-
-	data := make([]GraphiteDataPoint, 0, 1024)
-	start := time.Now()
-	timestamp := start.Unix()
+	return nil, f.metrics(res)
+}
 
-	err, res := f.ShowRuleStats(rule)
+func (f *Device) StatsRule(ctx context.Context, rname string) (error, []Metric) {
+	err, res := f.ShowRuleStats(ctx, rname)
 	if err != nil {
 		return err, nil
-	} else {
-
-		for key := range res.Entries {
-
-			skey := prefix + rulename + "." + key
-			var v interface{}
-			v = res.Entries[key]
-
-			if value, ok := v.(LBStatsValue); ok {
-				if value.Value > 0 {
-					// only print value if it is greater than zero
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				} else if f.StatsShowZeroes {
-					// except if I really want it
-					dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-					data = append(data, dp)
-				}
-			}
-
-		}
-
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
-
 	}
-
+	return nil, f.metrics(res)
 }
 
-func (f *Device) StatsRules() (error, []GraphiteDataPoint) {
-
-	data := make([]GraphiteDataPoint, 0, 4096)
-	start := time.Now()
-	timestamp := start.Unix()
-	err, res := f.ShowAllRuleStats()
+func (f *Device) StatsRules(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowAllRuleStats(ctx)
 	if err != nil {
 		return err, nil
-	} else {
-
-		splitter := func(c rune) bool {
-			// split if "/" or "~"
-			return c == '\u002f' || c == '\u007e'
-
-		}
-
-		for surl, stats := range res.Entries {
-
-			fields := strings.FieldsFunc(surl, splitter)
-			partition := fields[6]
-			rulename := fields[7]
-			if len(partition) < 1 || len(rulename) < 1 {
-				fmt.Fprintf(os.Stderr, "warn: cannot parse partition and rulename for rule given url: %s", surl)
-				continue
-			}
-
-			entries := structs.New(stats.NestedStats.Entries)
-			fnames := entries.Names()
-
-			prefix := f.StatsPathPrefix + partition + ".rule."
-
-			for key := range fnames {
-
-				skey := prefix + rulename + "." + fnames[key]
-				v := entries.Field(fnames[key])
-
-				if value, ok := v.Value().(LBStatsValue); ok {
-					if value.Value > 0 {
-						// only print value if it is greater than zero
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					} else if f.StatsShowZeroes {
-						// except if I really want it
-						dp := NewGraphiteDataPoint(skey, value.Value, timestamp)
-						data = append(data, dp)
-					}
-				}
-
-			}
-
-		}
-		//		elapsed := time.Since(start)
-		//		fmt.Printf("elapsed: %v\n", elapsed.Seconds())
-		return nil, data
 	}
-
+	return nil, f.metrics(res)
 }
@@ -0,0 +1,218 @@
+package f5
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LBLogicalDisk struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+	// sizes are in MB
+	Size       float64 `json:"size"`
+	VgFree     float64 `json:"vgFree"`
+	VgInUse    float64 `json:"vgInUse"`
+	VgReserved float64 `json:"vgReserved"`
+}
+
+type LBLogicalDisks struct {
+	Items []LBLogicalDisk `json:"items"`
+}
+
+// system stats have no partition. The object is the segment after the kind,
+// eg. /mgmt/tm/sys/cpu/0/cpuInfo/1 or /mgmt/tm/sys/performance/throughput/Client%20In
+func parseSysStatsPath(segments []string) StatsPath {
+
+	p := StatsPath{}
+	if len(segments) > 0 && (segments[0] == "performance" || segments[0] == "disk") {
+		segments = segments[1:]
+	}
+	if len(segments) < 2 {
+		return p
+	}
+	p.Kind = segments[0]
+	p.Object = segments[1]
+	rest := segments[2:]
+	switch {
+	case len(rest) == 1:
+		p.Member = rest[0]
+	case len(rest) > 1:
+		p.Subcollection = rest[0]
+		p.Member = rest[1]
+	}
+	return p
+
+}
+
+// performance stats are shown as text, eg. 4.2M
+func parsePerformanceValue(s string) (float64, bool) {
+
+	s = strings.TrimSpace(s)
+	multiplier := 1.0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1e3
+		case 'M':
+			multiplier = 1e6
+		case 'G':
+			multiplier = 1e9
+		case 'T':
+			multiplier = 1e12
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * multiplier, true
+
+}
+
+// performanceMetrics returns the statistics of a performance table, where each
+// row is an object and each column a statistic, eg. Current or Average
+func (f *Device) performanceMetrics(tree *LBStatsTree) []Metric {
+
+	data := make([]Metric, 0, 64)
+	sampled := time.Now()
+	WalkStats(tree, func(s Stat) {
+		if s.Path.Object == "" {
+			return
+		}
+		value, ok := s.Value, s.Numeric
+		if !ok {
+			// text that isn't a number is skipped, eg. the heading naming the row
+			if value, ok = parsePerformanceValue(s.Description); !ok {
+				return
+			}
+		}
+		// Max(since 2020_01_01T00:00:00Z) changes with every reset
+		name := s.Name
+		if i := strings.Index(name, "("); i > 0 {
+			name = name[:i]
+		}
+		data = f.appendMetric(data, newMetric(s.Path.Kind, "", s.Path.Object, s.Path.Member, name, value, sampled))
+	})
+	return data
+
+}
+
+func (f *Device) showSysStats(ctx context.Context, path string) (error, *LBStatsTree) {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/" + path
+	res := LBStatsTree{}
+
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, &res
+	}
+
+}
+
+func (f *Device) ShowCpuStats(ctx context.Context) (error, *LBStatsTree) {
+	return f.showSysStats(ctx, "cpu")
+}
+
+func (f *Device) ShowMemoryStats(ctx context.Context) (error, *LBStatsTree) {
+	return f.showSysStats(ctx, "memory")
+}
+
+func (f *Device) ShowThroughputStats(ctx context.Context) (error, *LBStatsTree) {
+	return f.showSysStats(ctx, "performance/throughput")
+}
+
+func (f *Device) ShowConnectionStats(ctx context.Context) (error, *LBStatsTree) {
+	return f.showSysStats(ctx, "performance/connections")
+}
+
+func (f *Device) ShowLogicalDisks(ctx context.Context) (error, *LBLogicalDisks) {
+
+	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/sys/disk/logical-disk"
+	res := LBLogicalDisks{}
+
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
+	if err != nil {
+		return err, nil
+	} else {
+		return nil, &res
+	}
+
+}
+
+func (f *Device) StatsCpu(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowCpuStats(ctx)
+	if err != nil {
+		return err, nil
+	}
+	return nil, f.metrics(res)
+}
+
+func (f *Device) StatsMemory(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowMemoryStats(ctx)
+	if err != nil {
+		return err, nil
+	}
+	return nil, f.metrics(res)
+}
+
+func (f *Device) StatsThroughput(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowThroughputStats(ctx)
+	if err != nil {
+		return err, nil
+	}
+	return nil, f.performanceMetrics(res)
+}
+
+func (f *Device) StatsConnections(ctx context.Context) (error, []Metric) {
+	err, res := f.ShowConnectionStats(ctx)
+	if err != nil {
+		return err, nil
+	}
+	return nil, f.performanceMetrics(res)
+}
+
+func (f *Device) StatsDisk(ctx context.Context) (error, []Metric) {
+
+	err, res := f.ShowLogicalDisks(ctx)
+	if err != nil {
+		return err, nil
+	}
+	data := make([]Metric, 0, 16)
+	sampled := time.Now()
+	for _, disk := range res.Items {
+		data = f.appendMetric(data, newMetric("disk", "", disk.Name, "", "size", disk.Size, sampled))
+		data = f.appendMetric(data, newMetric("disk", "", disk.Name, "", "vgFree", disk.VgFree, sampled))
+		data = f.appendMetric(data, newMetric("disk", "", disk.Name, "", "vgInUse", disk.VgInUse, sampled))
+		data = f.appendMetric(data, newMetric("disk", "", disk.Name, "", "vgReserved", disk.VgReserved, sampled))
+	}
+	return nil, data
+
+}
+
+// StatsSystem returns the cpu, memory, disk, throughput and connection stats of the device
+func (f *Device) StatsSystem(ctx context.Context) (error, []Metric) {
+
+	data := make([]Metric, 0, 256)
+	for _, stats := range []func(context.Context) (error, []Metric){
+		f.StatsCpu,
+		f.StatsMemory,
+		f.StatsDisk,
+		f.StatsThroughput,
+		f.StatsConnections,
+	} {
+		err, res := stats(ctx)
+		if err != nil {
+			return err, nil
+		}
+		data = append(data, res...)
+	}
+	return nil, data
+
+}
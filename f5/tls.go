@@ -0,0 +1,102 @@
+package f5
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// TLSConfig controls how the certificate of the device is checked
+type TLSConfig struct {
+	// PEM file of CAs to trust instead of the system roots
+	CAFile string
+	// name expected in the device certificate, when it doesn't match the hostname used
+	ServerName string
+	// SHA-256 fingerprints of the device certificate, any of which is accepted.
+	// Without a CAFile the certificate chain is not checked, only the fingerprint.
+	Fingerprints []string
+	// skip all checks - credentials can be read by anyone in the middle
+	Insecure bool
+}
+
+// normaliseFingerprint accepts fingerprints with or without colons, in either case
+func normaliseFingerprint(fp string) (error, string) {
+	fp = strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+	b, err := hex.DecodeString(fp)
+	if err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("error: %q is not a SHA-256 fingerprint", fp), ""
+	}
+	return nil, fp
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate in the usual
+// colon separated form
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// SetTLSConfig sets how the certificate of the device is checked. By default
+// it must be signed by a CA in the system roots and match the hostname.
+func (f *Device) SetTLSConfig(c TLSConfig) error {
+
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.Insecure,
+	}
+
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("error: no certificates found in CA bundle %s", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if len(c.Fingerprints) > 0 {
+		pins := make(map[string]bool)
+		for _, fp := range c.Fingerprints {
+			err, n := normaliseFingerprint(fp)
+			if err != nil {
+				return err
+			}
+			pins[n] = true
+		}
+		// a pinned certificate is trusted on its own, usually the self signed
+		// certificate of the management interface
+		if c.CAFile == "" {
+			conf.InsecureSkipVerify = true
+		}
+		conf.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("error: device presented no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !pins[hex.EncodeToString(sum[:])] {
+				cert, err := x509.ParseCertificate(rawCerts[0])
+				if err != nil {
+					return fmt.Errorf("error: device certificate does not match any pinned fingerprint")
+				}
+				return fmt.Errorf("error: device certificate %s does not match any pinned fingerprint", Fingerprint(cert))
+			}
+			return nil
+		}
+	}
+
+	f.transport.TLSClientConfig = conf
+	return nil
+
+}
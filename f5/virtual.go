@@ -1,9 +1,9 @@
 package f5
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -121,83 +121,12 @@ type LBVirtuals struct {
 	Items []LBVirtual
 }
 
-type LBVirtualStatsDescription struct {
-	Description string `json:"description"`
-}
-
-type LBVirtualStatsValue struct {
-	Value int `json:"value"`
-}
-
-type LBVirtualStatsInnerEntries struct {
-	Clientside_bitsIn             LBStatsValue              `json:"clientside.bitsIn"`
-	Clientside_bitsOut            LBStatsValue              `json:"clientside.bitsOut"`
-	Clientside_curConns           LBStatsValue              `json:"clientside.curConns"`
-	Clientside_evictedConns       LBStatsValue              `json:"clientside.evictedConns"`
-	Clientside_maxConns           LBStatsValue              `json:"clientside.maxConns"`
-	Clientside_pktsIn             LBStatsValue              `json:"clientside.pktsIn"`
-	Clientside_pktsOut            LBStatsValue              `json:"clientside.pktsOut"`
-	Clientside_slowKilled         LBStatsValue              `json:"clientside.slowKilled"`
-	Clientside_totConns           LBStatsValue              `json:"clientside.totConns"`
-	CmpEnableMode                 LBVirtualStatsDescription `json:"cmpEnableMode"`
-	CmpEnabled                    LBVirtualStatsDescription `json:"cmpEnabled"`
-	CsMaxConnDur                  LBStatsValue              `json:"csMaxConnDur"`
-	CsMeanConnDur                 LBStatsValue              `json:"csMeanConnDur"`
-	CsMinConnDur                  LBStatsValue              `json:"csMinConnDur"`
-	Destination                   LBVirtualStatsDescription `json:"destination"`
-	Ephemeral_bitsIn              LBStatsValue              `json:"ephemeral.bitsIn"`
-	Ephemeral_bitsOut             LBStatsValue              `json:"ephemeral.bitsOut"`
-	Ephemeral_curConns            LBStatsValue              `json:"ephemeral.curConns"`
-	Ephemeral_evictedConns        LBStatsValue              `json:"ephemeral.evictedConns"`
-	Ephemeral_maxConns            LBStatsValue              `json:"ephemeral.maxConns"`
-	Ephemeral_pktsIn              LBStatsValue              `json:"ephemeral.pktsIn"`
-	Ephemeral_pktsOut             LBStatsValue              `json:"ephemeral.pktsOut"`
-	Ephemeral_slowKilled          LBStatsValue              `json:"ephemeral.slowKilled"`
-	Ephemeral_totConns            LBStatsValue              `json:"ephemeral.totConns"`
-	FiveMinAvgUsageRatio          LBStatsValue              `json:"fiveMinAvgUsageRatio"`
-	FiveSecAvgUsageRatio          LBStatsValue              `json:"fiveSecAvgUsageRatio"`
-	TmName                        LBVirtualStatsDescription `json:"tmName"`
-	OneMinAvgUsageRatio           LBStatsValue              `json:"oneMinAvgUsageRatio"`
-	Status_availabilityState      LBVirtualStatsDescription `json:"status.availabilityState"`
-	Status_enabledState           LBVirtualStatsDescription `json:"status.enabledState"`
-	Status_statusReason           LBVirtualStatsDescription `json:"status.statusReason"`
-	SyncookieStatus               LBVirtualStatsDescription `json:"syncookieStatus"`
-	Syncookie_accepts             LBStatsValue              `json:"syncookie.accepts"`
-	Syncookie_hwAccepts           LBStatsValue              `json:"syncookie.hwAccepts"`
-	Syncookie_hwSyncookies        LBStatsValue              `json:"syncookie.hwSyncookies"`
-	Syncookie_hwsyncookieInstance LBStatsValue              `json:"syncookie.hwsyncookieInstance"`
-	Syncookie_rejects             LBStatsValue              `json:"syncookie.rejects"`
-	Syncookie_swsyncookieInstance LBStatsValue              `json:"syncookie.swsyncookieInstance"`
-	Syncookie_syncacheCurr        LBStatsValue              `json:"syncookie.syncacheCurr"`
-	Syncookie_syncacheOver        LBStatsValue              `json:"syncookie.syncacheOver"`
-	Syncookie_syncookies          LBStatsValue              `json:"syncookie.syncookies"`
-	TotRequests                   LBStatsValue              `json:"totRequests"`
-}
-
-type LBVirtualStatsNestedStats struct {
-	Kind     string                     `json:"kind"`
-	SelfLink string                     `json:"selfLink"`
-	Entries  LBVirtualStatsInnerEntries `json:"entries"`
-}
-
-type LBVirtualURLKey struct {
-	NestedStats LBVirtualStatsNestedStats `json:"nestedStats"`
-}
-type LBVirtualStatsOuterEntries map[string]LBVirtualURLKey
-
-type LBVirtualStats struct {
-	Kind       string                     `json:"kind"`
-	Generation int                        `json:"generation"`
-	SelfLink   string                     `json:"selfLink"`
-	Entries    LBVirtualStatsOuterEntries `json:"entries"`
-}
-
-func (f *Device) ShowVirtuals() (error, *LBVirtuals) {
+func (f *Device) ShowVirtuals(ctx context.Context, opts ...func(*ListOptions)) (error, *LBVirtuals) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual"
 	res := LBVirtuals{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err := f.list(ctx, u, &res, opts)
 	if err != nil {
 		return err, nil
 	} else {
@@ -206,13 +135,13 @@ func (f *Device) ShowVirtuals() (error, *LBVirtuals) {
 
 }
 
-func (f *Device) ShowVirtual(vname string) (error, *LBVirtual) {
+func (f *Device) ShowVirtual(ctx context.Context, vname string) (error, *LBVirtual) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual/" + vname + "?expandSubcollections=true"
 	res := LBVirtual{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -221,13 +150,13 @@ func (f *Device) ShowVirtual(vname string) (error, *LBVirtual) {
 
 }
 
-func (f *Device) ShowVirtualStats(vname string) (error, *LBObjectStats) {
+func (f *Device) ShowVirtualStats(ctx context.Context, vname string) (error, *LBStatsTree) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual/" + vname + "/stats"
-	res := LBObjectStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -235,12 +164,12 @@ func (f *Device) ShowVirtualStats(vname string) (error, *LBObjectStats) {
 	}
 }
 
-func (f *Device) ShowAllVirtualStats() (error, *LBVirtualStats) {
+func (f *Device) ShowAllVirtualStats(ctx context.Context) (error, *LBStatsTree) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual/stats"
-	res := LBVirtualStats{}
+	res := LBStatsTree{}
 
-	err, _ := f.sendRequest(u, GET, nil, &res)
+	err, _ := f.sendRequest(ctx, u, GET, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -248,13 +177,13 @@ func (f *Device) ShowAllVirtualStats() (error, *LBVirtualStats) {
 	}
 }
 
-func (f *Device) AddVirtual(virt *json.RawMessage) (error, *LBVirtual) {
+func (f *Device) AddVirtual(ctx context.Context, virt *json.RawMessage) (error, *LBVirtual) {
 
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual"
 	res := LBVirtual{}
 
 	// post the request
-	err, _ := f.sendRequest(u, POST, virt, &res)
+	err, _ := f.sendRequest(ctx, u, POST, virt, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -263,14 +192,14 @@ func (f *Device) AddVirtual(virt *json.RawMessage) (error, *LBVirtual) {
 
 }
 
-func (f *Device) UpdateVirtual(vname string, body *json.RawMessage) (error, *LBVirtual) {
+func (f *Device) UpdateVirtual(ctx context.Context, vname string, body *json.RawMessage) (error, *LBVirtual) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual/" + vname
 	res := LBVirtual{}
 
 	// put the request
-	err, _ := f.sendRequest(u, PUT, &body, &res)
+	err, _ := f.sendRequest(ctx, u, PUT, &body, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -279,8 +208,8 @@ func (f *Device) UpdateVirtual(vname string, body *json.RawMessage) (error, *LBV
 
 }
 
-func (f *Device) PatchVirtual(vname string, patch *LBVirtual) (error, *LBVirtual) {
-	vname = strings.Replace(vname, "/", "~", -1)
+func (f *Device) PatchVirtual(ctx context.Context, vname string, patch *LBVirtual) (error, *LBVirtual) {
+	vname = f.escapeName(vname)
 	url := fmt.Sprintf("%s://%s/mgmt/tm/ltm/virtual/%s", f.Proto, f.Hostname, vname)
 	existing := &LBVirtual{}
 	var err error
@@ -289,7 +218,7 @@ func (f *Device) PatchVirtual(vname string, patch *LBVirtual) (error, *LBVirtual
 	// the existing record's data  so that existing settings aren't overwritten,
 	// but instead added to.
 	if f.MergeStrategy() >= mergo.AppendAdditive {
-		err, existing = f.ShowVirtual(vname)
+		err, existing = f.ShowVirtual(ctx, vname)
 		if err != nil {
 			return err, nil
 		}
@@ -312,7 +241,7 @@ func (f *Device) PatchVirtual(vname string, patch *LBVirtual) (error, *LBVirtual
 		if cmp.Equal(new, existing, cmpopts.EquateEmpty()) {
 			return nil, existing
 		} else {
-			err, _ = f.sendRequest(url, PATCH, patch, existing)
+			err, _ = f.sendRequest(ctx, url, PATCH, patch, existing)
 			if err != nil {
 				return err, nil
 			} else {
@@ -322,13 +251,13 @@ func (f *Device) PatchVirtual(vname string, patch *LBVirtual) (error, *LBVirtual
 	}
 }
 
-func (f *Device) DeleteVirtual(vname string) (error, *Response) {
+func (f *Device) DeleteVirtual(ctx context.Context, vname string) (error, *Response) {
 
-	vname = strings.Replace(vname, "/", "~", -1)
+	vname = f.escapeName(vname)
 	u := f.Proto + "://" + f.Hostname + "/mgmt/tm/ltm/virtual/" + vname
 	res := json.RawMessage{}
 
-	err, resp := f.sendRequest(u, DELETE, nil, &res)
+	err, resp := f.sendRequest(ctx, u, DELETE, nil, &res)
 	if err != nil {
 		return err, nil
 	} else {
@@ -0,0 +1,141 @@
+package f5
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LBObjectStatus is the state of a virtual, pool, pool member or node, from its stats
+type LBObjectStatus struct {
+	// eg. available, offline or unknown
+	AvailabilityState string
+	// eg. enabled or disabled
+	EnabledState string
+	// eg. The virtual server is available
+	StatusReason string
+}
+
+// set fills in the status from a statistic, if it is part of it
+func (s *LBObjectStatus) set(stat Stat) {
+	switch stat.Name {
+	case "status.availabilityState":
+		s.AvailabilityState = stat.Description
+	case "status.enabledState":
+		s.EnabledState = stat.Description
+	case "status.statusReason":
+		s.StatusReason = stat.Description
+	}
+}
+
+func (s *LBObjectStatus) String() string {
+	state := s.AvailabilityState + ", " + s.EnabledState
+	if s.StatusReason != "" {
+		state += " - " + s.StatusReason
+	}
+	return state
+}
+
+// LBObjectRef names an object to wait for, eg.
+// {Kind: "poolmember", Pool: "/Common/web", Name: "/Common/10.1.1.1:80"}
+type LBObjectRef struct {
+	// virtual, pool, poolmember or node
+	Kind string
+	Name string
+	// the pool of a pool member
+	Pool string
+}
+
+func (o LBObjectRef) String() string {
+	if o.Kind == "poolmember" {
+		return "pool member " + o.Name + " in pool " + o.Pool
+	}
+	return o.Kind + " " + o.Name
+}
+
+// WaitState is the state WaitFor waits for. Empty fields match anything.
+type WaitState struct {
+	// eg. available, offline or unknown
+	Availability string
+	// eg. enabled or disabled
+	Enabled string
+}
+
+// Matches reports whether status is in the wanted state
+func (w WaitState) Matches(status *LBObjectStatus) bool {
+	return (w.Availability == "" || strings.EqualFold(w.Availability, status.AvailabilityState)) &&
+		(w.Enabled == "" || strings.EqualFold(w.Enabled, status.EnabledState))
+}
+
+func (w WaitState) String() string {
+	parts := make([]string, 0, 2)
+	if w.Availability != "" {
+		parts = append(parts, w.Availability)
+	}
+	if w.Enabled != "" {
+		parts = append(parts, w.Enabled)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// ObjectStatus returns the current state of a virtual, pool, pool member or node
+func (f *Device) ObjectStatus(ctx context.Context, obj LBObjectRef) (error, *LBObjectStatus) {
+
+	var show func(context.Context, string) (error, *LBStatsTree)
+	switch obj.Kind {
+	case "virtual":
+		show = f.ShowVirtualStats
+	case "pool":
+		show = f.ShowPoolStats
+	case "node":
+		show = f.ShowNodeStats
+	case "poolmember":
+		err, status := f.PoolMemberStatus(ctx, obj.Pool, obj.Name)
+		if err != nil {
+			return err, nil
+		}
+		return nil, &status.LBObjectStatus
+	default:
+		return fmt.Errorf("error: cannot wait for a %s - use virtual, pool, poolmember or node", obj.Kind), nil
+	}
+
+	err, res := show(ctx, obj.Name)
+	if err != nil {
+		return err, nil
+	}
+	status := LBObjectStatus{}
+	WalkStats(res, status.set)
+	if status.AvailabilityState == "" && status.EnabledState == "" {
+		return fmt.Errorf("error: no status in the stats of %s", obj), nil
+	}
+	return nil, &status
+
+}
+
+// WaitFor polls the status of an object every interval until it is in the
+// wanted state or ctx is done. report is called each time the status changes.
+func (f *Device) WaitFor(ctx context.Context, obj LBObjectRef, want WaitState, interval time.Duration, report func(*LBObjectStatus)) (error, *LBObjectStatus) {
+
+	var last *LBObjectStatus
+	for {
+		err, status := f.ObjectStatus(ctx, obj)
+		if err != nil {
+			return err, last
+		}
+		if report != nil && (last == nil || last.AvailabilityState != status.AvailabilityState || last.EnabledState != status.EnabledState) {
+			report(status)
+		}
+		last = status
+		if want.Matches(status) {
+			return nil, status
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error: gave up waiting for %s to be %s, it is %s : %s", obj, want, status, ctx.Err()), status
+		case <-time.After(interval):
+		}
+	}
+
+}
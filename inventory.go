@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// set in the environment of each command run by fanOut, naming the inventory
+// device it is running against
+const inventoryDeviceEnv = "F5_INVENTORY_DEVICE"
+
+// a device in an inventory file - the fields match those of f5.json
+type inventoryDevice struct {
+	Device          string `json:"device"`
+	Username        string `json:"username,omitempty"`
+	Passwd          string `json:"passwd,omitempty"`
+	Token           *bool  `json:"token,omitempty"`
+	Partition       string `json:"partition,omitempty"`
+	StatsPathPrefix string `json:"stats_path_prefix,omitempty"`
+	CABundle        string `json:"ca_bundle,omitempty"`
+	ServerName      string `json:"server_name,omitempty"`
+	Fingerprint     string `json:"fingerprint,omitempty"`
+	Insecure        *bool  `json:"insecure,omitempty"`
+}
+
+type inventory struct {
+	Defaults inventoryDevice            `json:"defaults"`
+	Devices  map[string]inventoryDevice `json:"devices"`
+	Groups   map[string][]string        `json:"groups"`
+}
+
+// the outcome of running a command against one device
+type fanOutResult struct {
+	name string
+	err  error
+}
+
+// longest line of output from a device, eg. a stack written as json
+const maxFanOutLine = 16 * 1024 * 1024
+
+// prefixLines copies each line read from r to w as it comes, prefixed with
+// the device name. mu keeps the lines of different devices whole.
+func prefixLines(r io.Reader, w io.Writer, name string, mu *sync.Mutex) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxFanOutLine)
+	for scanner.Scan() {
+		mu.Lock()
+		fmt.Fprintf(w, "[%s] %s\n", name, scanner.Text())
+		mu.Unlock()
+	}
+	if err := scanner.Err(); err != nil {
+		mu.Lock()
+		fmt.Fprintf(os.Stderr, "[%s] error reading output : %s\n", name, err)
+		mu.Unlock()
+		// keep the pipe drained so the command isn't blocked writing
+		io.Copy(ioutil.Discard, r)
+	}
+}
+
+func readInventory(path string) (error, *inventory) {
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err, nil
+	}
+	inv := inventory{}
+	if err := json.Unmarshal(dat, &inv); err != nil {
+		return fmt.Errorf("error parsing inventory %s : %s", path, err), nil
+	}
+	for name, dev := range inv.Devices {
+		if dev.Device == "" {
+			return fmt.Errorf("error: inventory device %s has no device address", name), nil
+		}
+		if _, ok := inv.Groups[name]; ok {
+			return fmt.Errorf("error: inventory name %s is both a device and a group", name), nil
+		}
+	}
+	return nil, &inv
+
+}
+
+// device returns an inventory device with any unset fields taken from the defaults
+func (inv *inventory) device(name string) inventoryDevice {
+
+	dev := inv.Devices[name]
+	if dev.Username == "" {
+		dev.Username = inv.Defaults.Username
+	}
+	if dev.Passwd == "" {
+		dev.Passwd = inv.Defaults.Passwd
+	}
+	if dev.Token == nil {
+		dev.Token = inv.Defaults.Token
+	}
+	if dev.Partition == "" {
+		dev.Partition = inv.Defaults.Partition
+	}
+	if dev.StatsPathPrefix == "" {
+		dev.StatsPathPrefix = inv.Defaults.StatsPathPrefix
+	}
+	if dev.CABundle == "" {
+		dev.CABundle = inv.Defaults.CABundle
+	}
+	if dev.ServerName == "" {
+		dev.ServerName = inv.Defaults.ServerName
+	}
+	if dev.Fingerprint == "" {
+		dev.Fingerprint = inv.Defaults.Fingerprint
+	}
+	if dev.Insecure == nil {
+		dev.Insecure = inv.Defaults.Insecure
+	}
+	return dev
+
+}
+
+// find returns the name of the inventory device known by any of the given
+// names or addresses, eg. the name and management address of a cluster member
+func (inv *inventory) find(keys ...string) (string, bool) {
+
+	names := make([]string, 0, len(inv.Devices))
+	for name := range inv.Devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		for _, name := range names {
+			if name == key || inv.Devices[name].Device == key {
+				return name, true
+			}
+		}
+	}
+	return "", false
+
+}
+
+// resolve turns a target selector into a list of device names. The selector
+// is a comma separated list of device names, group names or "all". Groups may
+// contain other groups.
+func (inv *inventory) resolve(target string) (error, []string) {
+
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	var add func(name string, path []string) error
+	add = func(name string, path []string) error {
+		if _, ok := inv.Devices[name]; ok {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return nil
+		}
+		members, ok := inv.Groups[name]
+		if !ok {
+			return fmt.Errorf("error: %s is not a device or group in the inventory", name)
+		}
+		for _, p := range path {
+			if p == name {
+				return fmt.Errorf("error: inventory group %s includes itself: %s", name, strings.Join(append(path, name), " -> "))
+			}
+		}
+		for _, member := range members {
+			if err := add(member, append(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range strings.Split(target, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			all := make([]string, 0, len(inv.Devices))
+			for dev := range inv.Devices {
+				all = append(all, dev)
+			}
+			sort.Strings(all)
+			for _, dev := range all {
+				add(dev, nil)
+			}
+			continue
+		}
+		if err := add(name, nil); err != nil {
+			return err, nil
+		}
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("error: target %q selects no devices", target), nil
+	}
+	return nil, names
+
+}
+
+// environ returns the environment for running f5er against a device
+func (dev inventoryDevice) environ(name string) []string {
+
+	env := os.Environ()
+	env = append(env, inventoryDeviceEnv+"="+name, "F5_DEVICE="+dev.Device)
+	if dev.Username != "" {
+		env = append(env, "F5_USERNAME="+dev.Username)
+	}
+	if dev.Passwd != "" {
+		env = append(env, "F5_PASSWD="+dev.Passwd)
+	}
+	if dev.Token != nil {
+		env = append(env, "F5_TOKEN="+strconv.FormatBool(*dev.Token))
+	}
+	if dev.Partition != "" {
+		env = append(env, "F5_PARTITION="+dev.Partition)
+	}
+	if dev.StatsPathPrefix != "" {
+		env = append(env, "F5_STATS_PATH_PREFIX="+dev.StatsPathPrefix)
+	}
+	if dev.CABundle != "" {
+		env = append(env, "F5_CA_BUNDLE="+dev.CABundle)
+	}
+	if dev.ServerName != "" {
+		env = append(env, "F5_SERVER_NAME="+dev.ServerName)
+	}
+	if dev.Fingerprint != "" {
+		env = append(env, "F5_FINGERPRINT="+dev.Fingerprint)
+	}
+	if dev.Insecure != nil {
+		env = append(env, "F5_INSECURE="+strconv.FormatBool(*dev.Insecure))
+	}
+	return env
+
+}
+
+// fanOut runs the current command once for each targeted device, all at the
+// same time. Each device gets its own f5er process so a failure on one device
+// can't affect the others. The output of each device is printed as it comes,
+// each line prefixed with the device name, and fanOut exits non-zero if the
+// command failed on any of them.
+func fanOut(cmd *cobra.Command, target string) {
+
+	// every process would listen on the same address
+	if top := topCommand(cmd); top.Name() == "serve-metrics" {
+		fmt.Fprint(os.Stderr, "\nerror: serve-metrics can't be used with --target; run it once for each device with its own --listen address\n\n")
+		os.Exit(1)
+	}
+
+	path := viper.GetString("inventory")
+	if path == "" {
+		fmt.Fprint(os.Stderr, "\nerror: --target requires an inventory; use --inventory, the inventory config option or F5_INVENTORY environment variable\n\n")
+		os.Exit(1)
+	}
+	err, inv := readInventory(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s\n\n", err)
+		os.Exit(1)
+	}
+	err, names := inv.resolve(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s\n\n", err)
+		os.Exit(1)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nerror: can't find the f5er executable : %s\n\n", err)
+		os.Exit(1)
+	}
+
+	var mu sync.Mutex
+	results := make([]chan *fanOutResult, len(names))
+	for i, name := range names {
+		results[i] = make(chan *fanOutResult, 1)
+		go func(name string, done chan *fanOutResult) {
+			res := &fanOutResult{name: name}
+			defer func() { done <- res }()
+			c := exec.Command(self, os.Args[1:]...)
+			c.Env = inv.device(name).environ(name)
+			stdout, err := c.StdoutPipe()
+			if err != nil {
+				res.err = err
+				return
+			}
+			stderr, err := c.StderrPipe()
+			if err != nil {
+				res.err = err
+				return
+			}
+			if res.err = c.Start(); res.err != nil {
+				return
+			}
+			// the pipes must be read to the end before waiting
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() { defer wg.Done(); prefixLines(stdout, os.Stdout, name, &mu) }()
+			go func() { defer wg.Done(); prefixLines(stderr, os.Stderr, name, &mu) }()
+			wg.Wait()
+			res.err = c.Wait()
+		}(name, results[i])
+	}
+
+	failed := make([]string, 0)
+	for i := range names {
+		res := <-results[i]
+		status := "ok"
+		if res.err != nil {
+			status = "failed: " + res.err.Error()
+			failed = append(failed, res.name)
+		}
+		mu.Lock()
+		fmt.Fprintf(os.Stderr, "==> %s (%s) %s\n", res.name, inv.Devices[res.name].Device, status)
+		mu.Unlock()
+	}
+
+	fmt.Fprintf(os.Stderr, "%d devices: %d succeeded, %d failed\n", len(names), len(names)-len(failed), len(failed))
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "failed: %s\n", strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+	os.Exit(0)
+
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/jmcvetta/napping"
 
@@ -15,6 +19,7 @@ import (
 
 var (
 	appliance           *f5.Device
+	ctx                 = context.Background()
 	f5Host              string
 	username            string
 	passwd              string
@@ -31,6 +36,57 @@ var (
 	now                 bool
 	statsPathPrefix     string
 	statsShowZeroValues bool
+	planOnly            bool
+	destroy             bool
+	target              string
+	inventoryFile       string
+	partition           string
+	standby             string
+	syncAfter           bool
+	syncGroup           string
+	syncTimeout         time.Duration
+	retries             int
+	retryWait           time.Duration
+	retryMaxWait        time.Duration
+	timeout             time.Duration
+	maxConcurrent       int
+	rateLimit           float64
+	caBundle            string
+	serverName          string
+	fingerprints        []string
+	insecure            bool
+	listPartition       string
+	listFilter          string
+	listFields          []string
+	statsFormat         string
+	metricsListen       string
+	scrapeInterval      time.Duration
+	carbonAddr          string
+	statsdAddr          string
+	influxUrl           string
+	influxToken         string
+	statsInterval       time.Duration
+	statsWorkers        int
+	drainTimeout        time.Duration
+	drainThreshold      int
+	drainInterval       time.Duration
+	drainForce          bool
+	rolloutBatch        int
+	rolloutExec         string
+	healthTimeout       time.Duration
+	waitState           string
+	waitEnabled         string
+	waitTimeout         time.Duration
+	waitInterval        time.Duration
+	ruleTcl             string
+	exportOutput        string
+	noLint              bool
+	emitStack           string
+	statsWatch          bool
+	watchSort           string
+	watchColumns        []string
+	watchTop            int
+	watchSamples        int
 	version             = "master"
 	commit              = "unstable"
 )
@@ -44,10 +100,24 @@ func initialiseConfig() {
 	viper.SetDefault("debug", false)
 	viper.SetDefault("token", false)
 	viper.SetDefault("force", false)
-	viper.SetDefault("statsPathPrefix", "f5")
-	viper.SetDefault("statsShowZeroValues", false)
+	viper.SetDefault("stats_path_prefix", "f5")
+	viper.SetDefault("stats_show_zero_values", false)
 	viper.SetDefault("dryrun", false)
 	viper.SetDefault("mergeStrategy", mergo.UniqueFirstSeen.String())
+	viper.SetDefault("standby", "refuse")
+	viper.SetDefault("sync", false)
+	viper.SetDefault("sync_timeout", 5*time.Minute)
+	viper.SetDefault("retries", 3)
+	viper.SetDefault("retry_wait", 500*time.Millisecond)
+	viper.SetDefault("retry_max_wait", 10*time.Second)
+	viper.SetDefault("timeout", 60*time.Second)
+	viper.SetDefault("max_concurrent", 0)
+	viper.SetDefault("rate_limit", 0)
+	viper.SetDefault("stats_format", "graphite")
+	viper.SetDefault("metrics_listen", ":9142")
+	viper.SetDefault("scrape_interval", 0)
+	viper.SetDefault("stats_interval", 0)
+	viper.SetDefault("stats_workers", 4)
 
 	viper.SetEnvPrefix("f5")
 	viper.BindEnv("device")
@@ -56,10 +126,50 @@ func initialiseConfig() {
 	viper.BindEnv("debug")
 	viper.BindEnv("token")
 	viper.BindEnv("dryrun")
+	viper.BindEnv("inventory")
+	viper.BindEnv("partition")
+	viper.BindEnv("stats_path_prefix")
+	viper.BindEnv("standby")
+	viper.BindEnv("sync")
+	viper.BindEnv("sync_group")
+	viper.BindEnv("ca_bundle")
+	viper.BindEnv("server_name")
+	viper.BindEnv("fingerprint")
+	viper.BindEnv("insecure")
+	viper.BindEnv("stats_format")
+	viper.BindEnv("metrics_listen")
+	viper.BindEnv("carbon")
+	viper.BindEnv("statsd")
+	viper.BindEnv("influx")
+	viper.BindEnv("influx_token")
 
 	viper.BindPFlag("f5", f5Cmd.PersistentFlags().Lookup("f5"))
 	viper.BindPFlag("debug", f5Cmd.PersistentFlags().Lookup("debug"))
 	viper.BindPFlag("input", f5Cmd.PersistentFlags().Lookup("input"))
+	viper.BindPFlag("target", f5Cmd.PersistentFlags().Lookup("target"))
+	viper.BindPFlag("inventory", f5Cmd.PersistentFlags().Lookup("inventory"))
+	viper.BindPFlag("standby", f5Cmd.PersistentFlags().Lookup("standby"))
+	viper.BindPFlag("sync", f5Cmd.PersistentFlags().Lookup("sync"))
+	viper.BindPFlag("sync_group", f5Cmd.PersistentFlags().Lookup("sync-group"))
+	viper.BindPFlag("sync_timeout", f5Cmd.PersistentFlags().Lookup("sync-timeout"))
+	viper.BindPFlag("retries", f5Cmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("retry_wait", f5Cmd.PersistentFlags().Lookup("retry-wait"))
+	viper.BindPFlag("retry_max_wait", f5Cmd.PersistentFlags().Lookup("retry-max-wait"))
+	viper.BindPFlag("timeout", f5Cmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("max_concurrent", f5Cmd.PersistentFlags().Lookup("max-concurrent"))
+	viper.BindPFlag("stats_workers", f5Cmd.PersistentFlags().Lookup("stats-workers"))
+	viper.BindPFlag("rate_limit", f5Cmd.PersistentFlags().Lookup("rate-limit"))
+	viper.BindPFlag("ca_bundle", f5Cmd.PersistentFlags().Lookup("ca-bundle"))
+	viper.BindPFlag("server_name", f5Cmd.PersistentFlags().Lookup("server-name"))
+	viper.BindPFlag("fingerprint", f5Cmd.PersistentFlags().Lookup("fingerprint"))
+	viper.BindPFlag("insecure", f5Cmd.PersistentFlags().Lookup("insecure"))
+	viper.BindPFlag("stats_format", statsCmd.PersistentFlags().Lookup("format"))
+	viper.BindPFlag("carbon", statsCmd.PersistentFlags().Lookup("carbon"))
+	viper.BindPFlag("statsd", statsCmd.PersistentFlags().Lookup("statsd"))
+	viper.BindPFlag("influx", statsCmd.PersistentFlags().Lookup("influx"))
+	viper.BindPFlag("stats_interval", statsCmd.PersistentFlags().Lookup("interval"))
+	viper.BindPFlag("metrics_listen", serveMetricsCmd.Flags().Lookup("listen"))
+	viper.BindPFlag("scrape_interval", serveMetricsCmd.Flags().Lookup("scrape-interval"))
 	viper.BindPFlag("dryrun", patchCmd.PersistentFlags().Lookup("dryrun"))
 	viper.BindPFlag("mergeStrategy", patchCmd.PersistentFlags().Lookup("merge-strategy"))
 	viper.BindPFlag("pool", onlinePoolMemberCmd.Flags().Lookup("pool"))
@@ -72,9 +182,17 @@ func initialiseConfig() {
 
 func checkFlags(cmd *cobra.Command) {
 
+	// with a target the command is run against each selected inventory device instead
+	if t := viper.GetString("target"); t != "" && os.Getenv(inventoryDeviceEnv) == "" {
+		fanOut(cmd, t)
+	}
+
 	debug = viper.GetBool("debug")
 	token = viper.GetBool("token")
-	now = viper.GetBool("now")
+	// --now belongs to whichever offline command is running
+	if f := cmd.Flags().Lookup("now"); f == nil || !f.Changed {
+		now = viper.GetBool("now")
+	}
 	username = viper.GetString("username")
 	passwd = viper.GetString("passwd")
 	f5Host = viper.GetString("device")
@@ -84,6 +202,28 @@ func checkFlags(cmd *cobra.Command) {
 	}
 	statsPathPrefix = viper.GetString("stats_path_prefix")
 	statsShowZeroValues = viper.GetBool("stats_show_zero_values")
+	partition = viper.GetString("partition")
+	standby = viper.GetString("standby")
+	syncTimeout = viper.GetDuration("sync_timeout")
+	retries = viper.GetInt("retries")
+	retryWait = viper.GetDuration("retry_wait")
+	retryMaxWait = viper.GetDuration("retry_max_wait")
+	timeout = viper.GetDuration("timeout")
+	maxConcurrent = viper.GetInt("max_concurrent")
+	rateLimit = viper.GetFloat64("rate_limit")
+	caBundle = viper.GetString("ca_bundle")
+	serverName = viper.GetString("server_name")
+	insecure = viper.GetBool("insecure")
+	statsFormat = viper.GetString("stats_format")
+	metricsListen = viper.GetString("metrics_listen")
+	scrapeInterval = viper.GetDuration("scrape_interval")
+	carbonAddr = viper.GetString("carbon")
+	statsdAddr = viper.GetString("statsd")
+	influxUrl = viper.GetString("influx")
+	influxToken = viper.GetString("influx_token")
+	statsInterval = viper.GetDuration("stats_interval")
+	statsWorkers = viper.GetInt("stats_workers")
+	fingerprints = splitFingerprints(viper.GetStringSlice("fingerprint"))
 
 	if username == "" {
 		fmt.Fprint(os.Stderr, "\nerror: missing username; use config file or F5_USERNAME environment variable\n\n")
@@ -100,14 +240,53 @@ func checkFlags(cmd *cobra.Command) {
 
 	// this has to be done here inside cobraCommand.Execute() inc case cmd line args are passed.
 	// args are only parsed after cobraCommand.Run() - urgh
-	appliance = f5.New(f5Host, username, passwd, f5.BASIC_AUTH)
-	appliance.SetDebug(debug)
-	appliance.SetTokenAuth(token)
-	appliance.SetStatsPathPrefix(statsPathPrefix)
-	appliance.SetStatsShowZeroes(statsShowZeroValues)
+	appliance = newAppliance(f5Host)
+
+	if isWriteCommand(cmd) {
+		ensureActive()
+	}
 
 }
 
+func newAppliance(host string) *f5.Device {
+	f := f5.New(host, username, passwd, f5.BASIC_AUTH)
+	f.SetDebug(debug)
+	f.SetTokenAuth(token)
+	f.SetStatsPathPrefix(statsPathPrefix)
+	f.SetStatsShowZeroes(statsShowZeroValues)
+	f.SetPartition(partition)
+	f.SetRetries(retries, retryWait, retryMaxWait)
+	f.SetTimeout(timeout)
+	f.SetMaxConcurrent(maxConcurrent)
+	f.SetRateLimit(rateLimit)
+	f.SetStatsWorkers(statsWorkers)
+	err := f.SetTLSConfig(f5.TLSConfig{
+		CAFile:       caBundle,
+		ServerName:   serverName,
+		Fingerprints: fingerprints,
+		Insecure:     insecure,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nerror: invalid tls options: %s\n\n", err)
+		os.Exit(1)
+	}
+	return f
+}
+
+// splitFingerprints allows fingerprints to be given as a list or a comma
+// separated string
+func splitFingerprints(list []string) []string {
+	fps := make([]string, 0)
+	for _, fp := range list {
+		for _, p := range strings.Split(fp, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				fps = append(fps, p)
+			}
+		}
+	}
+	return fps
+}
+
 func checkRequiredFlag(flg string) {
 	if !viper.IsSet(flg) {
 		fmt.Fprintf(os.Stdout, "\nerror: missing required option --%s\n\n", flg)
@@ -115,17 +294,101 @@ func checkRequiredFlag(flg string) {
 	}
 }
 
+// listOptions narrows down the objects listed by the show commands
+func listOptions() func(*f5.ListOptions) {
+	return func(o *f5.ListOptions) {
+		o.Partition = listPartition
+		o.Filter = listFilter
+		o.Fields = listFields
+	}
+}
+
+// printFields prints only the fields given with --fields of each object listed
+func printFields(items interface{}) {
+	err, v := toGeneric(items)
+	if err != nil {
+		log.Fatal(err)
+	}
+	shape := make(map[string]interface{}, len(listFields))
+	for _, f := range listFields {
+		shape[f] = nil
+	}
+	appliance.PrintObject(project(v, []interface{}{shape}))
+}
+
 func init() {
 
 	f5Cmd.PersistentFlags().StringVarP(&f5Host, "f5", "f", "", "IP or hostname of F5 to poke")
 	f5Cmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug output")
 	f5Cmd.PersistentFlags().BoolVarP(&token, "token", "t", false, "use token auth")
 	f5Cmd.PersistentFlags().StringVarP(&f5Input, "input", "i", "", "input json f5 configuration")
+	f5Cmd.PersistentFlags().StringVarP(&target, "target", "", "", "comma separated inventory devices or groups to run against")
+	f5Cmd.PersistentFlags().StringVarP(&inventoryFile, "inventory", "", "", "inventory json file of devices and groups")
+	f5Cmd.PersistentFlags().StringVarP(&standby, "standby", "", "refuse", "changes sent to a standby unit: refuse, redirect to the active unit or allow")
+	f5Cmd.PersistentFlags().BoolVarP(&syncAfter, "sync", "", false, "config-sync the device group after making changes")
+	f5Cmd.PersistentFlags().StringVarP(&syncGroup, "sync-group", "", "", "device group to sync, found automatically if not given")
+	f5Cmd.PersistentFlags().DurationVarP(&syncTimeout, "sync-timeout", "", 5*time.Minute, "how long to wait for the device group to be in sync")
+	f5Cmd.PersistentFlags().IntVarP(&retries, "retries", "", 3, "times to retry a GET, PUT or DELETE after a transient failure")
+	f5Cmd.PersistentFlags().DurationVarP(&retryWait, "retry-wait", "", 500*time.Millisecond, "wait before the first retry, doubled for each retry after")
+	f5Cmd.PersistentFlags().DurationVarP(&retryMaxWait, "retry-max-wait", "", 10*time.Second, "longest wait between retries")
+	f5Cmd.PersistentFlags().DurationVarP(&timeout, "timeout", "", 60*time.Second, "timeout for each request, 0 for none")
+	f5Cmd.PersistentFlags().IntVarP(&maxConcurrent, "max-concurrent", "", 0, "most requests to make to a device at once, 0 for no limit")
+	f5Cmd.PersistentFlags().Float64VarP(&rateLimit, "rate-limit", "", 0, "most requests to make to a device each second, 0 for no limit")
+	f5Cmd.PersistentFlags().IntVarP(&statsWorkers, "stats-workers", "", 4, "most stats endpoints to fetch at once when collecting all stats")
+	f5Cmd.PersistentFlags().StringVarP(&caBundle, "ca-bundle", "", "", "PEM file of CAs trusted to sign the device certificate")
+	f5Cmd.PersistentFlags().StringVarP(&serverName, "server-name", "", "", "name expected in the device certificate")
+	f5Cmd.PersistentFlags().StringSliceVarP(&fingerprints, "fingerprint", "", nil, "SHA-256 fingerprint of the device certificate to trust")
+	f5Cmd.PersistentFlags().BoolVarP(&insecure, "insecure", "", false, "don't check the device certificate at all")
 	patchCmd.PersistentFlags().BoolVarP(&dryrun, "dryrun", "r", false, "show what would be sent without making changes")
 	patchCmd.PersistentFlags().StringVarP(&mergeStrategy, "merge-strategy", "m", mergeStrategy, "Stategy for merging patch data; e.g., overwrite, append,\nunique-keep-patch, unique-keep-original")
 	offlinePoolMemberCmd.Flags().StringVarP(&f5Pool, "pool", "p", "", "F5 pool name")
 	offlinePoolMemberCmd.Flags().BoolVarP(&now, "now", "n", false, "force member offline immediately")
 	onlinePoolMemberCmd.Flags().StringVarP(&f5Pool, "pool", "p", "", "F5 pool name")
+	offlineNodeCmd.Flags().BoolVarP(&now, "now", "n", false, "force the node and its pool members offline immediately")
+	drainPoolMemberCmd.Flags().StringVarP(&f5Pool, "pool", "p", "", "F5 pool name")
+	drainPoolMemberCmd.Flags().DurationVarP(&drainTimeout, "timeout", "", 5*time.Minute, "how long to wait for connections to drain")
+	drainPoolMemberCmd.Flags().IntVarP(&drainThreshold, "threshold", "", 0, "connections left at which the member is drained")
+	drainPoolMemberCmd.Flags().DurationVarP(&drainInterval, "interval", "", 5*time.Second, "how often to check the connections")
+	drainPoolMemberCmd.Flags().BoolVarP(&drainForce, "force", "", false, "force the member offline once drained")
+	addRuleCmd.Flags().StringVarP(&ruleTcl, "tcl", "", "", "tcl file of the rule, instead of json given with --input")
+	updateRuleCmd.Flags().StringVarP(&ruleTcl, "tcl", "", "", "tcl file of the rule, instead of json given with --input")
+	addCmd.PersistentFlags().BoolVarP(&noLint, "no-lint", "", false, "send rules with lint errors to the device")
+	updateCmd.PersistentFlags().BoolVarP(&noLint, "no-lint", "", false, "send rules with lint errors to the device")
+	applyCmd.Flags().BoolVarP(&noLint, "no-lint", "", false, "send rules with lint errors to the device")
+	patchCmd.PersistentFlags().BoolVarP(&noLint, "no-lint", "", false, "send rules with lint errors to the device")
+	auditCmd.PersistentFlags().StringVarP(&listPartition, "partition", "", "", "only report objects in this partition")
+	auditOrphansCmd.Flags().StringVarP(&emitStack, "emit-stack", "", "", "write the orphans to a stack file for delete stack, - for stdout")
+	exportRuleCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write, - for stdout")
+	waitCmd.PersistentFlags().StringVarP(&waitState, "state", "", "available", "availability to wait for, eg. available, offline or unknown; empty for any")
+	waitCmd.PersistentFlags().StringVarP(&waitEnabled, "enabled", "", "", "enabled state to wait for, eg. enabled or disabled; empty for any")
+	waitCmd.PersistentFlags().DurationVarP(&waitTimeout, "wait-timeout", "", 5*time.Minute, "how long to wait for the state")
+	waitCmd.PersistentFlags().DurationVarP(&waitInterval, "interval", "", 5*time.Second, "how often to check the state")
+	waitPoolMemberCmd.Flags().StringVarP(&f5Pool, "pool", "p", "", "F5 pool name")
+	rolloutCmd.Flags().StringVarP(&f5Pool, "pool", "p", "", "F5 pool name")
+	rolloutCmd.Flags().IntVarP(&rolloutBatch, "batch", "", 1, "pool members to take out of service at once")
+	rolloutCmd.Flags().StringVarP(&rolloutExec, "exec", "", "", "command to run for each member once drained, eg. \"deploy.sh {member}\"; {address} and {pool} are replaced too")
+	rolloutCmd.Flags().DurationVarP(&drainTimeout, "drain-timeout", "", 5*time.Minute, "how long to wait for the connections of a batch to drain")
+	rolloutCmd.Flags().IntVarP(&drainThreshold, "threshold", "", 0, "connections left at which a member is drained")
+	rolloutCmd.Flags().DurationVarP(&drainInterval, "interval", "", 5*time.Second, "how often to check connections and health")
+	rolloutCmd.Flags().BoolVarP(&drainForce, "force", "", false, "force members offline once drained")
+	rolloutCmd.Flags().DurationVarP(&healthTimeout, "health-timeout", "", 5*time.Minute, "how long to wait for the members of a batch to be available again")
+	showCmd.PersistentFlags().StringVarP(&listPartition, "partition", "", "", "only list objects in this partition")
+	showCmd.PersistentFlags().StringVarP(&listFilter, "filter", "", "", "only list objects matching an iControl REST filter, eg. \"name eq web-pool\"")
+	showCmd.PersistentFlags().StringSliceVarP(&listFields, "fields", "", nil, "only fetch and show these fields of each object listed")
+	statsCmd.PersistentFlags().StringVarP(&statsFormat, "format", "", "graphite", "output format: graphite or prometheus")
+	statsCmd.PersistentFlags().StringVarP(&carbonAddr, "carbon", "", "", "send stats to carbon at host:port, tcp://host:port or udp://host:port")
+	statsCmd.PersistentFlags().StringVarP(&statsdAddr, "statsd", "", "", "send stats to statsd at host:port as gauges")
+	statsCmd.PersistentFlags().StringVarP(&influxUrl, "influx", "", "", "send stats to an influxdb write url, eg. http://influx:8086/write?db=f5")
+	statsCmd.PersistentFlags().DurationVarP(&statsInterval, "interval", "", 0, "collect stats on this interval until stopped")
+	statsCmd.PersistentFlags().BoolVarP(&statsWatch, "watch", "w", false, "show a refreshing table of rates and values instead of printing stats")
+	statsCmd.PersistentFlags().StringVarP(&watchSort, "sort", "", "", "statistic to sort the watch table by, busiest first, eg. clientside.curConns")
+	statsCmd.PersistentFlags().StringSliceVarP(&watchColumns, "columns", "", nil, "statistics to show in the watch table instead of those for the kind of object")
+	statsCmd.PersistentFlags().IntVarP(&watchTop, "top", "", 20, "most objects to show in the watch table, 0 for all")
+	statsCmd.PersistentFlags().IntVarP(&watchSamples, "samples", "", 0, "stop watching after this many samples, 0 to refresh until stopped")
+	serveMetricsCmd.Flags().StringVarP(&metricsListen, "listen", "", ":9142", "address to serve /metrics on")
+	serveMetricsCmd.Flags().DurationVarP(&scrapeInterval, "scrape-interval", "", 0, "scrape the device on this schedule instead of on every request")
+	applyCmd.Flags().BoolVarP(&planOnly, "plan", "", false, "only show the changes that would be made")
+	applyCmd.Flags().BoolVarP(&destroy, "destroy", "", false, "plan the removal of every object in the stack")
 
 	// version
 	f5Cmd.AddCommand(versionCmd)
@@ -146,6 +409,8 @@ func init() {
 	showCmd.AddCommand(showStackCmd)
 	showCmd.AddCommand(showCertCmd)
 	showCmd.AddCommand(showCertsCmd)
+	showCmd.AddCommand(showFailoverStatusCmd)
+	showCmd.AddCommand(showSyncStatusCmd)
 
 	// add
 	f5Cmd.AddCommand(addCmd)
@@ -186,6 +451,9 @@ func init() {
 	patchCmd.AddCommand(patchMonitorHttpCmd)
 	patchCmd.AddCommand(patchStackCmd)
 
+	// apply
+	f5Cmd.AddCommand(applyCmd)
+
 	// delete
 	f5Cmd.AddCommand(deleteCmd)
 	deleteCmd.AddCommand(deletePoolCmd)
@@ -202,10 +470,30 @@ func init() {
 	// offline
 	f5Cmd.AddCommand(offlineCmd)
 	offlineCmd.AddCommand(offlinePoolMemberCmd)
+	offlineCmd.AddCommand(offlineNodeCmd)
 
 	// online
 	f5Cmd.AddCommand(onlineCmd)
 	onlineCmd.AddCommand(onlinePoolMemberCmd)
+	onlineCmd.AddCommand(onlineNodeCmd)
+	f5Cmd.AddCommand(rolloutCmd)
+	f5Cmd.AddCommand(waitCmd)
+	f5Cmd.AddCommand(lintCmd)
+	lintCmd.AddCommand(lintRuleCmd)
+	f5Cmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportRulesCmd)
+	f5Cmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditOrphansCmd)
+	f5Cmd.AddCommand(validateCmd)
+	validateCmd.AddCommand(validateStackCmd)
+	f5Cmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportRuleCmd)
+	waitCmd.AddCommand(waitVirtualCmd)
+	waitCmd.AddCommand(waitPoolCmd)
+	waitCmd.AddCommand(waitPoolMemberCmd)
+	waitCmd.AddCommand(waitNodeCmd)
+	f5Cmd.AddCommand(drainCmd)
+	drainCmd.AddCommand(drainPoolMemberCmd)
 
 	f5Cmd.AddCommand(statsCmd)
 	statsCmd.AddCommand(statsPoolCmd)
@@ -213,8 +501,19 @@ func init() {
 	statsCmd.AddCommand(statsVirtualCmd)
 	statsCmd.AddCommand(statsNodeCmd)
 	statsCmd.AddCommand(statsRuleCmd)
+	statsCmd.AddCommand(statsSystemCmd)
+	statsCmd.AddCommand(statsAllCmd)
+	statsCmd.AddCommand(statsCpuCmd)
+	statsCmd.AddCommand(statsMemoryCmd)
+	statsCmd.AddCommand(statsDiskCmd)
+	statsCmd.AddCommand(statsThroughputCmd)
+	statsCmd.AddCommand(statsConnectionsCmd)
+
+	// serve-metrics
+	f5Cmd.AddCommand(serveMetricsCmd)
 
 	f5Cmd.AddCommand(uploadFileCmd)
+	f5Cmd.AddCommand(syncCmd)
 	f5Cmd.AddCommand(runCmd)
 
 	// read config
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// printStats prints statistics in the format given with --format
+func printStats(metrics []f5.Metric) {
+
+	switch statsFormat {
+	case "graphite":
+		for _, datapoint := range appliance.GraphiteDataPoints(metrics) {
+			fmt.Printf("%s\n", datapoint.String())
+		}
+	case "prometheus":
+		if err := f5.WritePrometheus(os.Stdout, metricsNamespace(), metrics); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("error: unknown stats format %q - use graphite or prometheus", statsFormat)
+	}
+
+}
+
+// metricsNamespace prefixes prometheus metric names, the stats path prefix without dots
+func metricsNamespace() string {
+	ns := strings.Replace(strings.Trim(appliance.StatsPathPrefix, "."), ".", "_", -1)
+	if ns == "" {
+		return "f5"
+	}
+	return ns
+}
+
+// scrape collects the statistics of the device in the prometheus format,
+// along with whether it worked and how long it took
+func scrape(ctx context.Context) []byte {
+
+	ns := metricsNamespace()
+	start := time.Now()
+	b := bytes.Buffer{}
+	up := 1
+
+	err, metrics := appliance.Stats(ctx)
+	if err == nil {
+		err = f5.WritePrometheus(&b, ns, metrics)
+	}
+	if err != nil {
+		log.Printf("error: scraping %s : %s", appliance.Hostname, err)
+		b.Reset()
+		up = 0
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_up whether the device could be scraped\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_up gauge\n", ns)
+	fmt.Fprintf(&b, "%s_up %d\n", ns, up)
+	fmt.Fprintf(&b, "# HELP %s_scrape_duration_seconds how long scraping the device took\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_scrape_duration_seconds gauge\n", ns)
+	fmt.Fprintf(&b, "%s_scrape_duration_seconds %f\n", ns, time.Since(start).Seconds())
+	return b.Bytes()
+
+}
+
+// serveMetrics serves the statistics of the device on /metrics. Without a
+// scrape interval the device is scraped on every request, otherwise the last
+// scrape is served.
+func serveMetrics() {
+
+	// series must not come and go as values drop to zero
+	appliance.SetStatsShowZeroes(true)
+
+	var mutex sync.Mutex
+	var last []byte
+	if scrapeInterval > 0 {
+		last = scrape(ctx)
+		go func() {
+			for range time.Tick(scrapeInterval) {
+				res := scrape(ctx)
+				mutex.Lock()
+				last = res
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		var res []byte
+		if scrapeInterval > 0 {
+			mutex.Lock()
+			res = last
+			mutex.Unlock()
+		} else {
+			res = scrape(r.Context())
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(res)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "<html><body><h1>f5er</h1><p><a href=\"/metrics\">metrics</a> of %s</p></body></html>\n", appliance.Hostname)
+	})
+
+	log.Printf("serving metrics of %s on %s/metrics", appliance.Hostname, metricsListen)
+	log.Fatal(http.ListenAndServe(metricsListen, mux))
+
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// setNodeState enables, disables or with --now forces offline a node given by
+// name or address, along with its member in every pool, in one transaction
+func setNodeState(name string, online bool) {
+
+	err, node := appliance.FindNode(ctx, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err, members := appliance.NodeMembers(ctx, node)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("node %s (%s) is a member of %d pools\n", node.FullPath, node.Address, len(members))
+
+	setMember := appliance.OfflinePoolMember
+	setNode := appliance.OfflineNode
+	action := "disabling"
+	switch {
+	case online:
+		setMember = appliance.OnlinePoolMember
+		setNode = appliance.OnlineNode
+		action = "enabling"
+	case now:
+		setMember = appliance.OfflinePoolMemberForced
+		setNode = appliance.OfflineNodeForced
+		action = "forcing offline"
+	}
+
+	err, tid := appliance.StartTransaction(ctx)
+	if err != nil {
+		log.Fatalf("error creating transaction: %s\n", err)
+	} else {
+		log.Printf("transaction %s created\n", tid)
+	}
+	tctx := f5.WithTransaction(ctx, tid)
+
+	for _, m := range members {
+		log.Printf("%s pool member %s in pool %s\n", action, m.FullPath, m.Pool)
+		err, _ := setMember(tctx, m.Pool, m.FullPath)
+		if err != nil {
+			abortTransaction(tid, fmt.Errorf("error %s pool member %s in pool %s : %s", action, m.FullPath, m.Pool, err))
+		}
+	}
+	log.Printf("%s node %s\n", action, node.FullPath)
+	err, _ = setNode(tctx, node.FullPath)
+	if err != nil {
+		abortTransaction(tid, fmt.Errorf("error %s node %s : %s", action, node.FullPath, err))
+	}
+
+	err = appliance.CommitTransaction(ctx, tid)
+	if err != nil {
+		log.Fatalf("error commiting transaction %s : %s\n", tid, err)
+	} else {
+		log.Printf("transaction committed : %s\n", tid)
+	}
+
+}
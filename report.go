@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// reportRules ranks iRules by the CPU cycles they have used, then lists those
+// with failures or aborts and those that are unused
+func reportRules() {
+
+	err, profiles := appliance.RuleProfiles(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tVIRTUALS\tEXECUTIONS\tTOTAL CYCLES\tAVG CYCLES\tMAX CYCLES\tFAILURES\tABORTS")
+	for _, p := range profiles {
+		avg, max := 0.0, 0.0
+		if n := p.Executions(); n > 0 {
+			avg = p.TotalCycles() / n
+		}
+		for _, e := range p.Events {
+			if e.MaxCycles > max {
+				max = e.MaxCycles
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", p.FullPath, len(p.Virtuals), humanise(p.Executions()),
+			humanise(p.TotalCycles()), humanise(avg), humanise(max), humanise(p.Failures()), humanise(p.Aborts()))
+	}
+	w.Flush()
+
+	failing := make([]string, 0)
+	for _, p := range profiles {
+		for _, e := range p.Events {
+			if e.Failures > 0 || e.Aborts > 0 {
+				failing = append(failing, fmt.Sprintf("%s %s: %.f failures, %.f aborts in %.f executions", p.FullPath, e.Event, e.Failures, e.Aborts, e.Executions))
+			}
+		}
+	}
+	if len(failing) > 0 {
+		fmt.Println("\nfailures and aborts:")
+		for _, f := range failing {
+			fmt.Println("  " + f)
+		}
+	}
+
+	unused := make([]*f5.LBRuleProfile, 0)
+	for _, p := range profiles {
+		if p.Unused() {
+			unused = append(unused, p)
+		}
+	}
+	if len(unused) > 0 {
+		fmt.Println("\nunused rules:")
+		for _, p := range unused {
+			if len(p.Virtuals) == 0 {
+				fmt.Printf("  %s: attached to no virtual\n", p.FullPath)
+			} else {
+				fmt.Printf("  %s: never executed on %d virtuals\n", p.FullPath, len(p.Virtuals))
+			}
+		}
+	}
+
+}
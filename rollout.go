@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// rollout takes the members of a pool out of service a batch at a time, so
+// something can be deployed to them without an outage
+type rollout struct {
+	pool      string
+	minActive int
+	// members of the current batch disabled by the rollout, to restore if it fails
+	disabled []f5.LBPoolMember
+}
+
+// outOfService reports whether a member was disabled or forced offline before
+// the rollout, so is put back that way rather than enabled
+func outOfService(m f5.LBPoolMember) bool {
+	return m.Session == "user-disabled" || m.State == "user-down"
+}
+
+// restore puts a member back as it was before the rollout - enabled, or
+// disabled or forced offline as it was found
+func (r *rollout) restore(m f5.LBPoolMember) error {
+
+	if !outOfService(m) {
+		log.Printf("enabling pool member %s\n", m.Name)
+		err, _ := appliance.OnlinePoolMember(ctx, r.pool, m.FullPath)
+		return err
+	}
+	state := f5.LBPoolMemberState{State: "user-up", Session: "user-disabled"}
+	if m.State == "user-down" {
+		state.State = "user-down"
+	}
+	log.Printf("leaving pool member %s %s, %s\n", m.Name, state.State, state.Session)
+	err, _ := appliance.SetPoolMemberState(ctx, r.pool, m.FullPath, state)
+	return err
+
+}
+
+// hookCommand runs --exec for a pool member, replacing {member}, {address}
+// and {pool} with the member name, its address and the pool
+func hookCommand(hook string, pool string, m f5.LBPoolMember) *exec.Cmd {
+
+	line := strings.NewReplacer("{member}", m.Name, "{address}", m.Address, "{pool}", pool).Replace(hook)
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", line)
+	} else {
+		c = exec.Command("sh", "-c", line)
+	}
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c
+
+}
+
+// abort restores the members of the current batch and gives up
+func (r *rollout) abort(format string, args ...interface{}) {
+
+	log.Printf(format, args...)
+	for _, m := range r.disabled {
+		if err := r.restore(m); err != nil {
+			log.Printf("error restoring pool member %s : %s\n", m.Name, err)
+		}
+	}
+	log.Fatalf("error: rollout of pool %s aborted\n", r.pool)
+
+}
+
+// checkActive makes sure taking batch out of service leaves enough members
+// taking connections - at least the minActiveMembers of the pool, and never
+// none at all
+func (r *rollout) checkActive(batch []f5.LBPoolMember) {
+
+	err, statuses := appliance.PoolMemberStatuses(ctx, r.pool)
+	if err != nil {
+		r.abort("error checking pool %s : %s\n", r.pool, err)
+	}
+	inBatch := make(map[string]bool)
+	for _, m := range batch {
+		inBatch[m.Name] = true
+	}
+	left := 0
+	for member, status := range statuses {
+		if status.Active() && !inBatch[member] {
+			left++
+		}
+	}
+	min := r.minActive
+	if min < 1 {
+		min = 1
+	}
+	if left < min {
+		r.abort("error: taking down %d members would leave %d active in pool %s, which needs %d\n", len(batch), left, r.pool, min)
+	}
+
+}
+
+// runBatch drains the members of batch, runs the hook for each of them and
+// puts them back as they were, waiting for those put back into service to be
+// available
+func (r *rollout) runBatch(batch []f5.LBPoolMember) {
+
+	r.checkActive(batch)
+
+	r.disabled = nil
+	for _, m := range batch {
+		if outOfService(m) {
+			// disabling a member forced offline would let connections back in
+			log.Printf("pool member %s is already out of service\n", m.Name)
+			r.disabled = append(r.disabled, m)
+			continue
+		}
+		log.Printf("disabling pool member %s\n", m.Name)
+		err, _ := appliance.OfflinePoolMember(ctx, r.pool, m.FullPath)
+		if err != nil {
+			r.abort("error disabling pool member %s : %s\n", m.Name, err)
+		}
+		r.disabled = append(r.disabled, m)
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, drainTimeout)
+	defer cancel()
+	for _, m := range batch {
+		name := m.Name
+		err, _ := appliance.WaitForDrain(dctx, r.pool, m.FullPath, float64(drainThreshold), drainInterval, func(conns float64) {
+			log.Printf("pool member %s: %.f connections\n", name, conns)
+		})
+		if err != nil {
+			r.abort("%s\n", err)
+		}
+	}
+	if drainForce {
+		for _, m := range batch {
+			log.Printf("forcing pool member %s offline\n", m.Name)
+			err, _ := appliance.OfflinePoolMemberForced(ctx, r.pool, m.FullPath)
+			if err != nil {
+				r.abort("error forcing pool member %s offline : %s\n", m.Name, err)
+			}
+		}
+	}
+
+	if rolloutExec != "" {
+		for _, m := range batch {
+			log.Printf("running hook for pool member %s\n", m.Name)
+			if err := hookCommand(rolloutExec, r.pool, m).Run(); err != nil {
+				r.abort("error: hook failed for pool member %s : %s\n", m.Name, err)
+			}
+		}
+	}
+
+	for _, m := range batch {
+		if err := r.restore(m); err != nil {
+			r.abort("error restoring pool member %s : %s\n", m.Name, err)
+		}
+	}
+
+	hctx, hcancel := context.WithTimeout(ctx, healthTimeout)
+	defer hcancel()
+	for _, m := range batch {
+		if outOfService(m) {
+			continue
+		}
+		name := m.Name
+		err, _ := appliance.WaitForAvailable(hctx, r.pool, m.FullPath, drainInterval, func(s *f5.LBObjectStatus) {
+			log.Printf("pool member %s: %s, %s\n", name, s.AvailabilityState, s.EnabledState)
+		})
+		if err != nil {
+			r.abort("%s\n", err)
+		}
+	}
+	r.disabled = nil
+
+}
+
+// rolloutPool runs a rollout across every member of pool, --batch at a time
+func rolloutPool(pool string) {
+
+	if rolloutBatch < 1 {
+		log.Fatal("error: --batch must be at least 1")
+	}
+	err, p := appliance.ShowPool(ctx, pool)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err, members := appliance.ShowPoolMembers(ctx, pool)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(members.Items) == 0 {
+		log.Fatalf("error: pool %s has no members\n", pool)
+	}
+
+	r := &rollout{pool: pool, minActive: p.MinActiveMembers}
+	for i := 0; i < len(members.Items); i += rolloutBatch {
+		end := i + rolloutBatch
+		if end > len(members.Items) {
+			end = len(members.Items)
+		}
+		batch := members.Items[i:end]
+		for j := range batch {
+			if batch[j].FullPath == "" {
+				batch[j].FullPath = "/" + batch[j].Partition + "/" + batch[j].Name
+			}
+		}
+		log.Printf("batch %d of %d\n", i/rolloutBatch+1, (len(members.Items)+rolloutBatch-1)/rolloutBatch)
+		r.runBatch(batch)
+	}
+	fmt.Printf("rolled out %d members of pool %s\n", len(members.Items), pool)
+
+}
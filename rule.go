@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// readTcl reads the TCL of an iRule from a file
+func readTcl(file string) []byte {
+	tcl, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("error reading tcl file: %s\n", err)
+	}
+	return tcl
+}
+
+// printLint shows the lint problems of a rule and returns how many are errors
+func printLint(source string, tcl string) int {
+	problems := f5.LintRule(tcl)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", source, p)
+	}
+	return f5.LintErrors(problems)
+}
+
+// checkLint stops before a rule with lint errors is sent to the device,
+// unless --no-lint is given
+func checkLint(source string, tcl string) {
+	if noLint {
+		return
+	}
+	if errors := printLint(source, tcl); errors > 0 {
+		log.Fatalf("error: %s has %d lint errors - fix them or use --no-lint\n", source, errors)
+	}
+}
+
+// checkStackLint lints the TCL of every rule in a stack
+func checkStackLint(stack *LBStack) {
+	for count, body := range stack.Rules {
+		rule := f5.LBRule{}
+		if err := json.Unmarshal(body, &rule); err != nil {
+			log.Fatalf("error parsing rule[%d]: %s\n", count, err)
+		}
+		checkLint(fmt.Sprintf("rule[%d] %s", count, rule.FullPath), rule.ApiAnonymous)
+	}
+}
+
+// lintRuleFiles lints tcl files, exiting non-zero if any have errors
+func lintRuleFiles(files []string) {
+	errors := 0
+	for _, file := range files {
+		errors += printLint(file, string(readTcl(file)))
+	}
+	if errors > 0 {
+		log.Fatalf("error: %d lint errors\n", errors)
+	}
+}
+
+// expandRuleFiles puts the TCL of each stack rule given as a file, eg.
+// {"fullPath": "/DMZ/redirect", "tcl": "rules/redirect.tcl"}, into its
+// apiAnonymous. Files are found relative to the stack file.
+func expandRuleFiles(stack *LBStack, dir string) error {
+
+	for count, body := range stack.Rules {
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return fmt.Errorf("error parsing rule[%d]: %s", count, err)
+		}
+		raw, ok := fields["tcl"]
+		if !ok {
+			continue
+		}
+		file := ""
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return fmt.Errorf("error parsing tcl of rule[%d]: %s", count, err)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		tcl, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error reading tcl of rule[%d]: %s", count, err)
+		}
+
+		// the device doesn't know about tcl files
+		rule := make(map[string]json.RawMessage)
+		for k, v := range fields {
+			if k != "tcl" {
+				rule[k] = v
+			}
+		}
+		rule["apiAnonymous"], _ = json.Marshal(string(tcl))
+		expanded, err := json.Marshal(rule)
+		if err != nil {
+			return fmt.Errorf("error building rule[%d]: %s", count, err)
+		}
+		stack.Rules[count] = expanded
+	}
+	return nil
+
+}
+
+// exportRule writes the TCL of an iRule to a file, named after the rule
+// unless given with --output, or to stdout for an output of -
+func exportRule(name string) {
+
+	err, rule := appliance.ShowRule(ctx, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tcl := rule.ApiAnonymous
+	if len(tcl) > 0 && tcl[len(tcl)-1] != '\n' {
+		tcl += "\n"
+	}
+	switch exportOutput {
+	case "-":
+		fmt.Print(tcl)
+		return
+	case "":
+		exportOutput = path.Base(rule.FullPath) + ".tcl"
+	}
+	err = ioutil.WriteFile(exportOutput, []byte(tcl), 0644)
+	if err != nil {
+		log.Fatalf("error writing tcl file: %s\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "rule %s written to %s\n", rule.FullPath, exportOutput)
+
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+const (
+	// most bytes sent in one write to carbon over tcp
+	tcpBatchSize = 64 * 1024
+	// most bytes sent in one datagram, small enough not to be fragmented
+	udpBatchSize = 1400
+	// most lines sent to influx in one request
+	influxBatchLines = 5000
+	// most lines kept after a failed send to carbon, statsd or influx, to be
+	// sent first on the next --interval
+	maxPendingLines = 100000
+)
+
+// a sink sends statistics somewhere other than stdout
+type sink interface {
+	Send(metrics []f5.Metric) error
+	Close() error
+}
+
+// stdoutSink prints statistics in the format given with --format
+type stdoutSink struct{}
+
+func (s stdoutSink) Send(metrics []f5.Metric) error {
+	printStats(metrics)
+	return nil
+}
+
+func (s stdoutSink) Close() error {
+	return nil
+}
+
+// lineSink writes lines over a tcp or udp connection in batches, connecting
+// again when a write fails. Lines that still can't be sent are kept for the
+// next send.
+type lineSink struct {
+	network string
+	addr    string
+	batch   int
+	conn    net.Conn
+	pending []string
+}
+
+// newLineSink takes an address of host:port, tcp://host:port or udp://host:port
+func newLineSink(addr string, network string) *lineSink {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network = addr[:i]
+		addr = addr[i+3:]
+	}
+	batch := tcpBatchSize
+	if strings.HasPrefix(network, "udp") {
+		batch = udpBatchSize
+	}
+	return &lineSink{network: network, addr: addr, batch: batch}
+}
+
+func (s *lineSink) write(b []byte) error {
+
+	// try a fresh connection once if the last one has gone away
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		_, err := s.conn.Write(b)
+		if err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+
+}
+
+// sendLines writes lines in batches of at most s.batch bytes, after any left
+// from the last send. When a batch fails it and the lines after it are kept.
+func (s *lineSink) sendLines(lines []string) error {
+
+	lines = append(s.pending, lines...)
+	s.pending = nil
+
+	buf := bytes.Buffer{}
+	// the first line in buf
+	first := 0
+	for i, line := range lines {
+		if buf.Len() > 0 && buf.Len()+len(line)+1 > s.batch {
+			if err := s.write(buf.Bytes()); err != nil {
+				s.keep(lines[first:])
+				return err
+			}
+			buf.Reset()
+			first = i
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() > 0 {
+		if err := s.write(buf.Bytes()); err != nil {
+			s.keep(lines[first:])
+			return err
+		}
+	}
+	return nil
+
+}
+
+// keep holds on to unsent lines, dropping the oldest beyond maxPendingLines
+func (s *lineSink) keep(lines []string) {
+	s.pending = keepLines(lines, s.addr)
+}
+
+// keepLines copies unsent lines to send later, dropping the oldest beyond
+// maxPendingLines
+func keepLines(lines []string, dest string) []string {
+	if over := len(lines) - maxPendingLines; over > 0 {
+		log.Printf("error: dropped %d unsent lines for %s, more than %d were waiting\n", over, dest, maxPendingLines)
+		lines = lines[over:]
+	}
+	return append([]string(nil), lines...)
+}
+
+func (s *lineSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// carbonSink sends graphite plaintext to carbon
+type carbonSink struct {
+	*lineSink
+}
+
+func (s carbonSink) Send(metrics []f5.Metric) error {
+	lines := make([]string, 0, len(metrics))
+	for _, datapoint := range appliance.GraphiteDataPoints(metrics) {
+		lines = append(lines, datapoint.String())
+	}
+	return s.sendLines(lines)
+}
+
+// statsdSink sends every statistic to statsd as a gauge
+type statsdSink struct {
+	*lineSink
+}
+
+func (s statsdSink) Send(metrics []f5.Metric) error {
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		lines = append(lines, m.GraphiteKey(appliance.StatsPathPrefix)+":"+strconv.FormatFloat(m.Value, 'f', -1, 64)+"|g")
+	}
+	return s.sendLines(lines)
+}
+
+// influxSink posts InfluxDB line protocol to a write url, eg.
+// http://influx:8086/write?db=f5 or http://influx:8086/api/v2/write?org=ops&bucket=f5.
+// Lines that can't be posted are kept for the next send.
+type influxSink struct {
+	url     string
+	token   string
+	client  *http.Client
+	pending []string
+}
+
+var (
+	influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxKeyEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+)
+
+// influxLine is eg. f5_pool,device=bigip01,object=web-pool,partition=Common curSessions=12 1465879005000000000
+func influxLine(m f5.Metric, device string) string {
+
+	b := strings.Builder{}
+	b.WriteString(influxKeyEscaper.Replace(metricsNamespace() + "_" + m.Kind))
+	b.WriteString(",device=" + influxTagEscaper.Replace(device))
+	if m.Member != "" {
+		b.WriteString(",member=" + influxTagEscaper.Replace(m.Member))
+	}
+	if m.Object != "" {
+		b.WriteString(",object=" + influxTagEscaper.Replace(m.Object))
+	}
+	if m.Partition != "" {
+		b.WriteString(",partition=" + influxTagEscaper.Replace(m.Partition))
+	}
+	b.WriteString(" " + influxTagEscaper.Replace(m.Name) + "=" + strconv.FormatFloat(m.Value, 'f', -1, 64))
+	b.WriteString(" " + strconv.FormatInt(m.Time.UnixNano(), 10))
+	return b.String()
+
+}
+
+func (s *influxSink) post(body []byte) error {
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error: influx write failed : %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+
+}
+
+// Send posts lines in batches of at most influxBatchLines, after any left from
+// the last send. When a batch fails it and the lines after it are kept.
+func (s *influxSink) Send(metrics []f5.Metric) error {
+
+	lines := s.pending
+	s.pending = nil
+	for _, m := range metrics {
+		lines = append(lines, influxLine(m, appliance.Hostname))
+	}
+
+	for first := 0; first < len(lines); first += influxBatchLines {
+		last := first + influxBatchLines
+		if last > len(lines) {
+			last = len(lines)
+		}
+		body := strings.Join(lines[first:last], "\n") + "\n"
+		if err := s.post([]byte(body)); err != nil {
+			s.pending = keepLines(lines[first:], "influx")
+			return err
+		}
+	}
+	return nil
+
+}
+
+func (s *influxSink) Close() error {
+	return nil
+}
+
+// openSinks returns the sinks given with --carbon, --statsd and --influx,
+// stdout if there are none
+func openSinks() []sink {
+
+	sinks := make([]sink, 0)
+	if carbonAddr != "" {
+		sinks = append(sinks, carbonSink{newLineSink(carbonAddr, "tcp")})
+	}
+	if statsdAddr != "" {
+		sinks = append(sinks, statsdSink{newLineSink(statsdAddr, "udp")})
+	}
+	if influxUrl != "" {
+		sinks = append(sinks, &influxSink{url: influxUrl, token: influxToken, client: &http.Client{Timeout: 30 * time.Second}})
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, stdoutSink{})
+	}
+	return sinks
+
+}
+
+// collectStats sends the statistics returned by get to every sink, once or
+// every --interval. When collecting on an interval failures are logged and
+// collection carries on. With --watch a table of rates is shown instead.
+func collectStats(get func(context.Context) (error, []f5.Metric)) {
+
+	if statsWatch {
+		watchStats(get)
+		return
+	}
+
+	sinks := openSinks()
+	defer func() {
+		for _, s := range sinks {
+			s.Close()
+		}
+	}()
+
+	fail := log.Fatal
+	if statsInterval > 0 {
+		fail = log.Print
+	}
+
+	next := time.Now()
+	for {
+		err, metrics := get(ctx)
+		if err != nil {
+			fail(err)
+		} else {
+			for _, s := range sinks {
+				if err := s.Send(metrics); err != nil {
+					fail(err)
+				}
+			}
+		}
+
+		if statsInterval <= 0 {
+			return
+		}
+		// keep to the schedule however long collecting took
+		next = next.Add(statsInterval)
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		} else {
+			next = time.Now()
+		}
+	}
+
+}
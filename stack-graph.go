@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// a reference from one object to another, eg. a virtual to its pool
+type stackRef struct {
+	kind string
+	path string
+}
+
+// a single object from a stack file
+type stackObject struct {
+	kind     *stackKind
+	index    int
+	fullPath string
+	body     json.RawMessage
+	refs     []stackRef
+}
+
+func (o *stackObject) String() string {
+	return o.kind.name + " " + o.fullPath
+}
+
+// qualify turns a possibly relative object name into a full path
+func qualify(name string, partition string) string {
+	if name == "" || name == "none" || strings.HasPrefix(name, "/") {
+		return name
+	}
+	if partition == "" {
+		partition = "Common"
+	}
+	return "/" + partition + "/" + name
+}
+
+// objectPath works out the full path of an object embedded in another, which
+// may only be given a name and optionally a partition
+func objectPath(fullPath string, name string, partition string, parent string) string {
+	if fullPath != "" {
+		return qualify(fullPath, parent)
+	}
+	if partition != "" {
+		return qualify(name, partition)
+	}
+	return qualify(name, parent)
+}
+
+// memberNode returns the full path of the node a pool member runs on, by
+// stripping the service port from the member name.
+// ie. /DMZ/webserver01:80 -> /DMZ/webserver01
+func memberNode(m *f5.LBPoolMember, parent string) string {
+	path := objectPath(m.FullPath, m.Name, m.Partition, parent)
+	// ipv6 members use a dot to separate the port from the address
+	sep := ":"
+	if strings.Count(path, ":") > 1 {
+		sep = "."
+	}
+	if i := strings.LastIndex(path, sep); i > 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// monitorRefs splits a monitor rule such as "/Common/http and /Common/tcp"
+// or "min 1 of { http tcp }" into the monitors it uses
+func monitorRefs(rule string, partition string) []stackRef {
+	refs := []stackRef{}
+	for _, word := range strings.Fields(rule) {
+		switch word {
+		case "and", "min", "of", "{", "}", "none":
+			continue
+		}
+		if strings.Trim(word, "0123456789") == "" {
+			continue
+		}
+		refs = append(refs, stackRef{"monitor", qualify(word, partition)})
+	}
+	return refs
+}
+
+// parseStackObject reads the object at index count of a kind in a stack file
+func parseStackObject(kind *stackKind, count int, body json.RawMessage) (error, *stackObject) {
+
+	// convert json to a struct - make sure it is valid
+	if err := json.Unmarshal(body, kind.object()); err != nil {
+		return fmt.Errorf("error parsing %s[%d]: %s", kind.name, count, err), nil
+	}
+	obj := struct {
+		FullPath string `json:"fullPath"`
+	}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return fmt.Errorf("error parsing %s[%d]: %s", kind.name, count, err), nil
+	}
+	if obj.FullPath == "" {
+		return fmt.Errorf("error: %s[%d] has no fullPath", kind.name, count), nil
+	}
+
+	refs := make([]stackRef, 0)
+	for _, ref := range kind.refs(body) {
+		if ref.path != "" && ref.path != "none" {
+			refs = append(refs, ref)
+		}
+	}
+
+	return nil, &stackObject{
+		kind:     kind,
+		index:    count,
+		fullPath: obj.FullPath,
+		body:     body,
+		refs:     refs,
+	}
+
+}
+
+// stackObjects flattens a stack into a list of objects, in the order of
+// stackKinds and then the order they appear in the stack file
+func stackObjects(stack *LBStack) (error, []*stackObject) {
+
+	objects := make([]*stackObject, 0)
+	for _, kind := range stackKinds {
+		for count, body := range kind.items(stack) {
+			err, obj := parseStackObject(kind, count, body)
+			if err != nil {
+				return err, nil
+			}
+			objects = append(objects, obj)
+		}
+	}
+	return nil, objects
+
+}
+
+// stackDeps returns, for each object, the other objects in the stack it refers to
+func stackDeps(objects []*stackObject) [][]int {
+
+	index := make(map[stackRef]int)
+	for i, obj := range objects {
+		index[stackRef{obj.kind.name, obj.fullPath}] = i
+	}
+
+	deps := make([][]int, len(objects))
+	for i, obj := range objects {
+		seen := make(map[int]bool)
+		for _, ref := range obj.refs {
+			j, ok := index[ref]
+			if ok && j != i && !seen[j] {
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps
+
+}
+
+// sortStack orders the objects of a stack so that every object comes after
+// the objects it refers to. Objects that don't depend on each other keep the
+// order of stackKinds and the stack file.
+func sortStack(stack *LBStack) (error, []*stackObject) {
+
+	err, objects := stackObjects(stack)
+	if err != nil {
+		return err, nil
+	}
+	deps := stackDeps(objects)
+
+	sorted := make([]*stackObject, 0, len(objects))
+	placed := make([]bool, len(objects))
+	for len(sorted) < len(objects) {
+		progress := false
+		for i, obj := range objects {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !placed[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[i] = true
+				sorted = append(sorted, obj)
+				progress = true
+				// start again so earlier objects keep their place
+				break
+			}
+		}
+		if !progress {
+			cycle := make([]string, 0)
+			for i, obj := range objects {
+				if !placed[i] {
+					cycle = append(cycle, obj.String())
+				}
+			}
+			return fmt.Errorf("error: dependency cycle between stack objects: %s", strings.Join(cycle, ", ")), nil
+		}
+	}
+	return nil, sorted
+
+}
+
+// deleteWaves groups sorted stack objects for deletion. The first wave holds
+// the objects nothing else in the stack refers to, the next wave the objects
+// only referred to by the first, and so on.
+func deleteWaves(sorted []*stackObject) [][]*stackObject {
+
+	if len(sorted) == 0 {
+		return nil
+	}
+	deps := stackDeps(sorted)
+	level := make([]int, len(sorted))
+	depth := 0
+
+	// dependencies always come first in sorted, so walking backwards visits
+	// every object before the objects it refers to
+	for i := len(sorted) - 1; i >= 0; i-- {
+		for _, j := range deps[i] {
+			if level[i]+1 > level[j] {
+				level[j] = level[i] + 1
+			}
+		}
+		if level[i] > depth {
+			depth = level[i]
+		}
+	}
+
+	waves := make([][]*stackObject, depth+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		waves[level[i]] = append(waves[level[i]], sorted[i])
+	}
+	return waves
+
+}
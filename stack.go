@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/rabbitt/f5er/f5"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 )
 
 type LBStack struct {
@@ -19,6 +22,32 @@ type LBStack struct {
 
 type LBEmptyBody struct{}
 
+// readStack loads the stack input file given by --input
+func readStack() LBStack {
+
+	stack := LBStack{}
+
+	// read in json file
+	dat, err := ioutil.ReadFile(f5Input)
+	if err != nil {
+		log.Fatalf("error reading input json file: %s\n", err)
+	}
+
+	// convert json to a stack struct
+	err = json.Unmarshal(dat, &stack)
+	if err != nil {
+		log.Fatalf("error unmarshaling input json file into a stack: %s\n", err)
+	}
+
+	// rules can keep their TCL in a file of its own
+	err = expandRuleFiles(&stack, filepath.Dir(f5Input))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return stack
+}
+
 func showStack() {
 
 	stack := LBStack{}
@@ -46,7 +75,7 @@ func showStack() {
 		}
 		log.Printf("\nserver-ssl[%d]: %s\n", count, obj.FullPath)
 
-		err, res := appliance.ShowServerSsl(obj.FullPath)
+		err, res := appliance.ShowServerSsl(ctx, obj.FullPath)
 		if err != nil {
 			log.Printf("error showing server-ssl %s : %s\n", obj.FullPath, err)
 		} else {
@@ -66,7 +95,7 @@ func showStack() {
 		}
 		log.Printf("\nclient-ssl[%d]: %s\n", count, obj.FullPath)
 
-		err, res := appliance.ShowClientSsl(obj.FullPath)
+		err, res := appliance.ShowClientSsl(ctx, obj.FullPath)
 		if err != nil {
 			log.Printf("error showing client-ssl %s : %s\n", obj.FullPath, err)
 		} else {
@@ -86,7 +115,7 @@ func showStack() {
 		}
 		log.Printf("\nnode[%d]: %s\n", count, node.FullPath)
 
-		err, res := appliance.ShowNode(node.FullPath)
+		err, res := appliance.ShowNode(ctx, node.FullPath)
 		if err != nil {
 			log.Printf("error showing node %s : %s\n", node.FullPath, err)
 		} else {
@@ -105,7 +134,7 @@ func showStack() {
 
 		log.Printf("\npool[%d]: %s\n", count, pool.FullPath)
 
-		err, res := appliance.ShowPool(pool.FullPath)
+		err, res := appliance.ShowPool(ctx, pool.FullPath)
 		if err != nil {
 			log.Printf("error showing pool %s : %s\n", pool.FullPath, err)
 		} else {
@@ -125,7 +154,7 @@ func showStack() {
 		}
 		log.Printf("\nrule[%d]: %s\n", count, obj.FullPath)
 
-		err, res := appliance.ShowRule(obj.FullPath)
+		err, res := appliance.ShowRule(ctx, obj.FullPath)
 		if err != nil {
 			log.Printf("error showing rule %s : %s\n", obj.FullPath, err)
 		} else {
@@ -145,7 +174,7 @@ func showStack() {
 		}
 		log.Printf("\npolicy[%d]: %s\n", count, obj.FullPath)
 
-		err, res := appliance.ShowPolicy(obj.FullPath)
+		err, res := appliance.ShowPolicy(ctx, obj.FullPath)
 		if err != nil {
 			log.Printf("error showing policy %s : %s\n", obj.FullPath, err)
 		} else {
@@ -163,7 +192,7 @@ func showStack() {
 		}
 		log.Printf("\nvirtual[%d]: %s\n", count, virt.FullPath)
 
-		err, res := appliance.ShowVirtual(virt.FullPath)
+		err, res := appliance.ShowVirtual(ctx, virt.FullPath)
 		if err != nil {
 			log.Printf("error showing virtual %s : %s\n", virt.FullPath, err)
 		} else {
@@ -174,175 +203,349 @@ func showStack() {
 
 }
 
-func addStack() {
+// stackKind describes how to list, show, add, update and delete one type of
+// object found in a stack file, and which other objects it refers to.
+type stackKind struct {
+	name   string
+	items  func(stack *LBStack) []json.RawMessage
+	object func() interface{}
+	refs   func(body json.RawMessage) []stackRef
+	// every object of the kind on the device, with its subcollections
+	list   func(ctx context.Context) (error, interface{})
+	show   func(ctx context.Context, name string) (error, interface{})
+	add    func(ctx context.Context, body *json.RawMessage) (error, interface{})
+	update func(ctx context.Context, name string, body *json.RawMessage) (error, interface{})
+	remove func(ctx context.Context, name string) (error, interface{})
+}
 
-	stack := LBStack{}
-	// read in json file
-	dat, err := ioutil.ReadFile(f5Input)
-	if err != nil {
-		log.Fatal(err)
-	}
+// expandList lists objects along with their subcollections, eg. the members
+// of each pool
+func expandList(o *f5.ListOptions) {
+	o.Expand = true
+}
 
-	// convert json to a stack struct
-	err = json.Unmarshal(dat, &stack)
-	if err != nil {
-		log.Fatal(err)
-	}
+// every type of object a stack can hold. The order only matters for objects
+// that don't depend on each other - otherwise objects are ordered by their
+// references, see sortStack.
+var stackKinds = []*stackKind{
+	{
+		name:   "server-ssl",
+		items:  func(s *LBStack) []json.RawMessage { return s.ServerSsl },
+		object: func() interface{} { return &f5.LBServerSsl{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBServerSsl{}
+			json.Unmarshal(b, &obj)
+			return []stackRef{
+				{"server-ssl", qualify(obj.DefaultsFrom, obj.Partition)},
+				{"cert", qualify(obj.Cert, obj.Partition)},
+				{"key", qualify(obj.Key, obj.Partition)},
+				{"cert", qualify(obj.Chain, obj.Partition)},
+			}
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowServerSsls(ctx)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowServerSsl(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddServerSsl(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdateServerSsl(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeleteServerSsl(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "client-ssl",
+		items:  func(s *LBStack) []json.RawMessage { return s.ClientSsl },
+		object: func() interface{} { return &f5.LBClientSsl{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBClientSsl{}
+			json.Unmarshal(b, &obj)
+			refs := []stackRef{
+				{"client-ssl", qualify(obj.DefaultsFrom, obj.Partition)},
+				{"cert", qualify(obj.Cert, obj.Partition)},
+				{"key", qualify(obj.Key, obj.Partition)},
+				{"cert", qualify(obj.Chain, obj.Partition)},
+			}
+			for _, ckc := range obj.CertKeyChain {
+				refs = append(refs,
+					stackRef{"cert", qualify(ckc.Cert, obj.Partition)},
+					stackRef{"key", qualify(ckc.Key, obj.Partition)},
+					stackRef{"cert", qualify(ckc.Chain, obj.Partition)},
+				)
+			}
+			return refs
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowClientSsls(ctx)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowClientSsl(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddClientSsl(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdateClientSsl(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeleteClientSsl(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "node",
+		items:  func(s *LBStack) []json.RawMessage { return s.Nodes },
+		object: func() interface{} { return &f5.LBNode{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBNode{}
+			json.Unmarshal(b, &obj)
+			return monitorRefs(obj.Monitor, obj.Partition)
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowNodes(ctx)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowNode(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddNode(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdateNode(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeleteNode(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "pool",
+		items:  func(s *LBStack) []json.RawMessage { return s.Pools },
+		object: func() interface{} { return &f5.LBPool{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBPool{}
+			json.Unmarshal(b, &obj)
+			refs := monitorRefs(obj.Monitor, obj.Partition)
+			for _, m := range obj.Members {
+				refs = append(refs, stackRef{"node", memberNode(&m, obj.Partition)})
+				refs = append(refs, monitorRefs(m.Monitor, obj.Partition)...)
+			}
+			return refs
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowPools(ctx, expandList)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowPool(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddPool(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdatePool(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeletePool(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "rule",
+		items:  func(s *LBStack) []json.RawMessage { return s.Rules },
+		object: func() interface{} { return &f5.LBRule{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBRule{}
+			json.Unmarshal(b, &obj)
+			// the device won't save a rule that names a pool it doesn't have
+			refs := []stackRef{}
+			for _, pool := range f5.RuleRefs(obj.ApiAnonymous).Pools {
+				refs = append(refs, stackRef{"pool", qualify(pool, obj.Partition)})
+			}
+			return refs
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowRules(ctx, func(o *f5.ListOptions) {
+				o.Fields = []string{"name", "partition", "fullPath", "apiAnonymous"}
+			})
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowRule(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddRule(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdateRule(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeleteRule(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "policy",
+		items:  func(s *LBStack) []json.RawMessage { return s.Policies },
+		object: func() interface{} { return &f5.LBPolicy{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBPolicy{}
+			json.Unmarshal(b, &obj)
+			refs := []stackRef{}
+			for _, rule := range obj.Rules {
+				for _, action := range rule.Actions {
+					refs = append(refs, stackRef{"pool", qualify(action.Pool, obj.Partition)})
+				}
+			}
+			return refs
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowPolicies(ctx, expandList)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowPolicy(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddPolicy(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdatePolicy(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeletePolicy(ctx, n)
+			return err, res
+		},
+	},
+	{
+		name:   "virtual",
+		items:  func(s *LBStack) []json.RawMessage { return s.Virtuals },
+		object: func() interface{} { return &f5.LBVirtual{} },
+		refs: func(b json.RawMessage) []stackRef {
+			obj := f5.LBVirtual{}
+			json.Unmarshal(b, &obj)
+			refs := []stackRef{{"pool", qualify(obj.Pool, obj.Partition)}}
+			for _, rule := range obj.Rules {
+				refs = append(refs, stackRef{"rule", qualify(rule, obj.Partition)})
+			}
+			for _, policy := range obj.Policies {
+				refs = append(refs, stackRef{"policy", objectPath(policy.FullPath, policy.Name, policy.Partition, obj.Partition)})
+			}
+			for _, profile := range obj.Profiles {
+				// a virtual doesn't say what type of profile it is using
+				path := objectPath(profile.FullPath, profile.Name, profile.Partition, obj.Partition)
+				refs = append(refs, stackRef{"client-ssl", path}, stackRef{"server-ssl", path})
+			}
+			return refs
+		},
+		list: func(ctx context.Context) (error, interface{}) {
+			err, res := appliance.ShowVirtuals(ctx, expandList)
+			return err, res
+		},
+		show: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.ShowVirtual(ctx, n)
+			return err, res
+		},
+		add: func(ctx context.Context, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.AddVirtual(ctx, b)
+			return err, res
+		},
+		update: func(ctx context.Context, n string, b *json.RawMessage) (error, interface{}) {
+			err, res := appliance.UpdateVirtual(ctx, n, b)
+			return err, res
+		},
+		remove: func(ctx context.Context, n string) (error, interface{}) {
+			err, res := appliance.DeleteVirtual(ctx, n)
+			return err, res
+		},
+	},
+}
+
+// abortTransaction discards a transaction after one of its commands failed
+// and exits non-zero, so a partially applied stack is never committed
+func abortTransaction(tid string, cause error) {
 
-	err, tid := appliance.StartTransaction()
+	err, cmds := appliance.ShowTransactionCommands(ctx, tid)
 	if err != nil {
-		log.Fatalf("error creating transaction: %s\n", err)
+		log.Printf("error listing commands of transaction %s : %s\n", tid, err)
 	} else {
-		log.Printf("transaction %s created\n", tid)
-	}
-
-	// add server-ssl
-	for count, n := range stack.ServerSsl {
-
-		obj := f5.LBServerSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nserver-ssl[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.AddServerSsl(&n)
-		if err != nil {
-			log.Printf("error adding server-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// add client-ssl
-	for count, n := range stack.ClientSsl {
-
-		obj := f5.LBClientSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nclient-ssl[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.AddClientSsl(&n)
-		if err != nil {
-			log.Printf("error adding client-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+		log.Printf("discarding %d queued commands of transaction %s\n", len(cmds.Items), tid)
+		for _, cmd := range cmds.Items {
+			log.Printf("  %d: %s %s\n", cmd.EvalOrder, cmd.Method, cmd.Uri)
 		}
-
 	}
 
-	// add nodes
-	for count, n := range stack.Nodes {
-
-		node := f5.LBNode{}
-		// convert json to a node struct - make sure it is valid
-		err = json.Unmarshal(n, &node)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nnode[%d]: %s\n", count, node.FullPath)
-
-		err, res := appliance.AddNode(&n)
-		if err != nil {
-			log.Printf("error adding node %s : %s\n", node.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
+	err = appliance.DeleteTransaction(ctx, tid)
+	if err != nil {
+		log.Printf("error deleting transaction %s : %s\n", tid, err)
 	}
 
-	// add pools
-	for count, p := range stack.Pools {
+	log.Fatalf("transaction %s aborted : %s\n", tid, cause)
 
-		pool := f5.LBPool{}
-		// convert json to a pool struct - make sure it is valid
-		if err := json.Unmarshal(p, &pool); err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npool[%d]: %s\n", count, pool.FullPath)
+}
 
-		err, res := appliance.AddPool(&p)
-		if err != nil {
-			log.Printf("error adding pool %s : %s\n", pool.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
+func addStack() {
 
+	stack := readStack()
+	checkStackLint(&stack)
+	err, objects := sortStack(&stack)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// add rules
-	for count, n := range stack.Rules {
-
-		obj := f5.LBRule{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nrule[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.AddRule(&n)
-		if err != nil {
-			log.Printf("error adding rule %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
+	err, tid := appliance.StartTransaction(ctx)
+	if err != nil {
+		log.Fatalf("error creating transaction: %s\n", err)
+	} else {
+		log.Printf("transaction %s created\n", tid)
 	}
+	// queue everything below in the transaction
+	tctx := f5.WithTransaction(ctx, tid)
 
-	// add policies
-	for count, n := range stack.Policies {
+	for _, obj := range objects {
 
-		obj := f5.LBPolicy{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npolicy[%d]: %s\n", count, obj.FullPath)
+		log.Printf("\n%s[%d]: %s\n", obj.kind.name, obj.index, obj.fullPath)
 
 		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.AddPolicy(&n)
-		if err != nil {
-			log.Printf("error adding policy %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// add virtual
-	for count, v := range stack.Virtuals {
-
-		virt := f5.LBVirtual{}
-		// convert json to a virtual struct - make sure it is valid
-		if err := json.Unmarshal(v, &virt); err != nil {
-			log.Fatal(err)
-		}
-
-		log.Printf("\nvirtual[%d]: %s\n", count, virt.FullPath)
-
-		err, res := appliance.AddVirtual(&v)
+		err, res := obj.kind.add(tctx, &obj.body)
 		if err != nil {
-			log.Printf("error adding virtual %s : %s\n", virt.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error adding %s %s : %s", obj.kind.name, obj.fullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
 	// if we made it here - commit the transaction - remove the transaction header first
-	err = appliance.CommitTransaction(tid)
+	err = appliance.CommitTransaction(ctx, tid)
 	if err != nil {
-		log.Printf("error commiting transaction %s : %s\n", tid, err)
+		log.Fatalf("error commiting transaction %s : %s\n", tid, err)
 	} else {
 		log.Printf("transaction committed : %s\n", tid)
 	}
@@ -351,171 +554,37 @@ func addStack() {
 
 func updateStack() {
 
-	stack := LBStack{}
-	// read in json file
-	dat, err := ioutil.ReadFile(f5Input)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// convert json to a stack struct
-	err = json.Unmarshal(dat, &stack)
+	stack := readStack()
+	checkStackLint(&stack)
+	err, objects := sortStack(&stack)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err, tid := appliance.StartTransaction()
+	err, tid := appliance.StartTransaction(ctx)
 	if err != nil {
 		log.Fatalf("error creating transaction: %s\n", err)
 	} else {
 		log.Printf("transaction %s created\n", tid)
 	}
+	tctx := f5.WithTransaction(ctx, tid)
 
-	// update server-ssl
-	for count, n := range stack.ServerSsl {
-
-		obj := f5.LBServerSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nserver-ssl[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.UpdateServerSsl(obj.FullPath, &n)
-		if err != nil {
-			log.Printf("error updating server-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// update client-ssl
-	for count, n := range stack.ServerSsl {
-
-		obj := f5.LBClientSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nclient-ssl[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.UpdateClientSsl(obj.FullPath, &n)
-		if err != nil {
-			log.Printf("error updating client-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// nodes
-	for count, n := range stack.Nodes {
-
-		node := f5.LBNode{}
-		// convert json to a node struct
-		err = json.Unmarshal(n, &node)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		log.Printf("\nnode[%d]: %s\n", count, node.FullPath)
-
-		err, res := appliance.UpdateNode(node.FullPath, &n)
-		if err != nil {
-			log.Printf("error adding virtual %s : %s\n", node.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// pools
-	for count, p := range stack.Pools {
-
-		pool := f5.LBPool{}
-		if err := json.Unmarshal(p, &pool); err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npool[%d]: %s\n", count, pool.FullPath)
-
-		err, res := appliance.UpdatePool(pool.FullPath, &p)
-		if err != nil {
-			log.Printf("error adding pool %s : %s\n", pool.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// update rules
-	for count, n := range stack.Rules {
-
-		obj := f5.LBRule{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nrule[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.UpdateRule(obj.FullPath, &n)
-		if err != nil {
-			log.Printf("error updating rule %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// update policies
-	for count, n := range stack.Policies {
-
-		obj := f5.LBPolicy{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npolicy[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.UpdatePolicy(obj.FullPath, &n)
-		if err != nil {
-			log.Printf("error updating policy %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// update virtual
-	for count, v := range stack.Virtuals {
+	for _, obj := range objects {
 
-		virt := f5.LBVirtual{}
-		if err := json.Unmarshal(v, &virt); err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nvirtual[%d]: %s\n", count, virt.FullPath)
+		log.Printf("\n%s[%d]: %s\n", obj.kind.name, obj.index, obj.fullPath)
 
-		err, res := appliance.UpdateVirtual(virt.FullPath, &v)
+		err, res := obj.kind.update(tctx, obj.fullPath, &obj.body)
 		if err != nil {
-			log.Printf("error adding virtual %s : %s\n", virt.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error updating %s %s : %s", obj.kind.name, obj.fullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
 	// if we made it here - commit the transaction
-	err = appliance.CommitTransaction(tid)
+	err = appliance.CommitTransaction(ctx, tid)
 	if err != nil {
-		log.Printf("error commiting transaction %s : %s\n", tid, err)
+		log.Fatalf("error commiting transaction %s : %s\n", tid, err)
 	} else {
 		log.Printf("transaction committed : %s\n", tid)
 	}
@@ -524,25 +593,16 @@ func updateStack() {
 
 func patchStack() {
 
-	stack := LBStack{}
-	// read in json file
-	dat, err := ioutil.ReadFile(f5Input)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// convert json to a stack struct
-	err = json.Unmarshal(dat, &stack)
-	if err != nil {
-		log.Fatal(err)
-	}
+	stack := readStack()
+	checkStackLint(&stack)
 
-	err, tid := appliance.StartTransaction()
+	err, tid := appliance.StartTransaction(ctx)
 	if err != nil {
 		log.Fatalf("error creating transaction: %s\n", err)
 	} else {
 		log.Printf("transaction %s created\n", tid)
 	}
+	tctx := f5.WithTransaction(ctx, tid)
 
 	// patch server-ssl
 	for count, n := range stack.ServerSsl {
@@ -551,38 +611,36 @@ func patchStack() {
 		// convert json to a struct - make sure it is valid
 		err = json.Unmarshal(n, &obj)
 		if err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 		log.Printf("\nserver-ssl[%d]: %s\n", count, obj.FullPath)
 
 		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.PatchServerSsl(obj.FullPath, &obj)
+		err, res := appliance.PatchServerSsl(tctx, obj.FullPath, &obj)
 		if err != nil {
-			log.Printf("error updating server-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching server-ssl %s : %s", obj.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
 	// patch client-ssl
-	for count, n := range stack.ServerSsl {
+	for count, n := range stack.ClientSsl {
 
 		obj := f5.LBClientSsl{}
 		// convert json to a struct - make sure it is valid
 		err = json.Unmarshal(n, &obj)
 		if err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 		log.Printf("\nclient-ssl[%d]: %s\n", count, obj.FullPath)
 
 		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.PatchClientSsl(obj.FullPath, &obj)
+		err, res := appliance.PatchClientSsl(tctx, obj.FullPath, &obj)
 		if err != nil {
-			log.Printf("error updating client-ssl %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching client-ssl %s : %s", obj.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
@@ -593,17 +651,16 @@ func patchStack() {
 		// convert json to a node struct
 		err = json.Unmarshal(n, &node)
 		if err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 
 		log.Printf("\nnode[%d]: %s\n", count, node.FullPath)
 
-		err, res := appliance.PatchNode(node.FullPath, &node)
+		err, res := appliance.PatchNode(tctx, node.FullPath, &node)
 		if err != nil {
-			log.Printf("error adding virtual %s : %s\n", node.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching node %s : %s", node.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
@@ -612,16 +669,15 @@ func patchStack() {
 
 		pool := f5.LBPool{}
 		if err := json.Unmarshal(p, &pool); err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 		log.Printf("\npool[%d]: %s\n", count, pool.FullPath)
 
-		err, res := appliance.PatchPool(pool.FullPath, &pool)
+		err, res := appliance.PatchPool(tctx, pool.FullPath, &pool)
 		if err != nil {
-			log.Printf("error adding pool %s : %s\n", pool.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching pool %s : %s", pool.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
@@ -632,231 +688,103 @@ func patchStack() {
 		// convert json to a struct - make sure it is valid
 		err = json.Unmarshal(n, &obj)
 		if err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 		log.Printf("\npolicy[%d]: %s\n", count, obj.FullPath)
 
 		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.PatchPolicy(obj.FullPath, &obj)
+		err, res := appliance.PatchPolicy(tctx, obj.FullPath, &obj)
 		if err != nil {
-			log.Printf("error updating policy %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching policy %s : %s", obj.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
-	// patch virtual
-	for count, v := range stack.Virtuals {
+	// patch rules
+	for count, n := range stack.Rules {
 
-		virt := f5.LBVirtual{}
-		if err := json.Unmarshal(v, &virt); err != nil {
-			log.Fatal(err)
+		obj := f5.LBRule{}
+		if err := json.Unmarshal(n, &obj); err != nil {
+			abortTransaction(tid, err)
 		}
-		log.Printf("\nvirtual[%d]: %s\n", count, virt.FullPath)
+		log.Printf("\nrule[%d]: %s\n", count, obj.FullPath)
 
-		err, res := appliance.PatchVirtual(virt.FullPath, &virt)
+		err, res := appliance.PatchRule(tctx, obj.FullPath, &obj)
 		if err != nil {
-			log.Printf("error adding virtual %s : %s\n", virt.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching rule %s : %s", obj.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
-	// if we made it here - commit the transaction
-	err = appliance.CommitTransaction(tid)
-	if err != nil {
-		log.Printf("error commiting transaction %s : %s\n", tid, err)
-	} else {
-		log.Printf("transaction committed : %s\n", tid)
-	}
-
-}
-
-func deleteStack() {
-
-	stack := LBStack{}
-	// read in json file
-	dat, err := ioutil.ReadFile(f5Input)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// convert json to a stack struct
-	err = json.Unmarshal(dat, &stack)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err, tid := appliance.StartTransaction()
-	if err != nil {
-		log.Fatalf("error creating transaction: %s\n", err)
-	} else {
-		log.Printf("transaction %s created\n", tid)
-	}
-
-	// virtual
+	// patch virtual
 	for count, v := range stack.Virtuals {
 
 		virt := f5.LBVirtual{}
 		if err := json.Unmarshal(v, &virt); err != nil {
-			log.Fatal(err)
+			abortTransaction(tid, err)
 		}
 		log.Printf("\nvirtual[%d]: %s\n", count, virt.FullPath)
 
-		err, res := appliance.DeleteVirtual(virt.FullPath)
-		if err != nil {
-			log.Printf("error deleting virtual %s : %s\n", virt.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
-	}
-
-	// pools
-	for count, p := range stack.Pools {
-
-		pool := f5.LBPool{}
-		if err := json.Unmarshal(p, &pool); err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npool[%d]: %s\n", count, pool.FullPath)
-
-		err, res := appliance.DeletePool(pool.FullPath)
+		err, res := appliance.PatchVirtual(tctx, virt.FullPath, &virt)
 		if err != nil {
-			log.Printf("error deleting pool %s : %s\n", pool.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
+			abortTransaction(tid, fmt.Errorf("error patching virtual %s : %s", virt.FullPath, err))
 		}
+		appliance.PrintObject(&res)
 
 	}
 
 	// if we made it here - commit the transaction
-	err = appliance.CommitTransaction(tid)
-	if err != nil {
-		log.Printf("error commiting transaction %s : %s\n", tid, err)
-	} else {
-		log.Printf("transaction committed : %s\n\n", tid)
-	}
-
-	// another transaction for all the other objects
-	err, tid = appliance.StartTransaction()
+	err = appliance.CommitTransaction(ctx, tid)
 	if err != nil {
-		log.Fatalf("error creating transaction: %s\n", err)
+		log.Fatalf("error commiting transaction %s : %s\n", tid, err)
 	} else {
-		log.Printf("transaction %s created\n", tid)
-	}
-
-	// delete policies
-	for count, n := range stack.Policies {
-
-		obj := f5.LBPolicy{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\npolicy[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.DeletePolicy(obj.FullPath)
-		if err != nil {
-			log.Printf("error deleting policy %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
-
+		log.Printf("transaction committed : %s\n", tid)
 	}
 
-	// delete rules
-	for count, n := range stack.Rules {
-
-		obj := f5.LBRule{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nrule[%d]: %s\n", count, obj.FullPath)
+}
 
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.DeleteRule(obj.FullPath)
-		if err != nil {
-			log.Printf("error deleting rule %s : %s\n", obj.FullPath, err)
-		} else {
-			appliance.PrintObject(&res)
-		}
+func deleteStack() {
 
+	stack := readStack()
+	err, objects := sortStack(&stack)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// delete client-ssl
-	for count, n := range stack.ClientSsl {
-
-		obj := f5.LBClientSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nclient-ssl[%d]: %s\n", count, obj.FullPath)
+	// objects can't be deleted in the same transaction as something that
+	// still refers to them, so each wave gets a transaction of its own
+	for _, wave := range deleteWaves(objects) {
 
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.DeleteClientSsl(obj.FullPath)
+		err, tid := appliance.StartTransaction(ctx)
 		if err != nil {
-			log.Printf("error deleting client-ssl %s : %s\n", obj.FullPath, err)
+			log.Fatalf("error creating transaction: %s\n", err)
 		} else {
-			appliance.PrintObject(&res)
+			log.Printf("transaction %s created\n", tid)
 		}
+		tctx := f5.WithTransaction(ctx, tid)
 
-	}
+		for _, obj := range wave {
 
-	// delete server-ssl
-	for count, n := range stack.ServerSsl {
+			log.Printf("\n%s[%d]: %s\n", obj.kind.name, obj.index, obj.fullPath)
 
-		obj := f5.LBServerSsl{}
-		// convert json to a struct - make sure it is valid
-		err = json.Unmarshal(n, &obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("\nserver-ssl[%d]: %s\n", count, obj.FullPath)
-
-		// use the raw json to add - only set minimal number of fields
-		err, res := appliance.DeleteServerSsl(obj.FullPath)
-		if err != nil {
-			log.Printf("error deleting server-ssl %s : %s\n", obj.FullPath, err)
-		} else {
+			err, res := obj.kind.remove(tctx, obj.fullPath)
+			if err != nil {
+				abortTransaction(tid, fmt.Errorf("error deleting %s %s : %s", obj.kind.name, obj.fullPath, err))
+			}
 			appliance.PrintObject(&res)
-		}
 
-	}
-
-	// delete nodes outside of transaction - pools depend on them and won't delete otherwise
-	for count, n := range stack.Nodes {
-
-		node := f5.LBNode{}
-		// convert json to a node struct
-		err = json.Unmarshal(n, &node)
-		if err != nil {
-			log.Fatal(err)
 		}
-		log.Printf("\nnode[%d]: %s\n", count, node.FullPath)
 
-		err, res := appliance.DeleteNode(node.FullPath)
+		// if we made it here - commit the transaction
+		err = appliance.CommitTransaction(ctx, tid)
 		if err != nil {
-			log.Printf("error deleting node %s : %s\n", node.FullPath, err)
+			log.Fatalf("error commiting transaction %s : %s\n", tid, err)
 		} else {
-			appliance.PrintObject(&res)
+			log.Printf("transaction committed : %s\n\n", tid)
 		}
-	}
 
-	// if we made it here - commit the transaction
-	err = appliance.CommitTransaction(tid)
-	if err != nil {
-		log.Printf("error commiting transaction %s : %s\n", tid, err)
-	} else {
-		log.Printf("transaction committed : %s\n", tid)
 	}
 
 }
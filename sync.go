@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rabbitt/f5er/f5"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// top level commands that change the configuration of a device
+var writeCommands = map[string]bool{
+	"add":     true,
+	"update":  true,
+	"patch":   true,
+	"delete":  true,
+	"apply":   true,
+	"online":  true,
+	"offline": true,
+	"drain":   true,
+	"rollout": true,
+	"upload":  true,
+	"sync":    true,
+	"run":     true,
+}
+
+// topCommand returns the top level command cmd belongs to, eg. add for add pool
+func topCommand(cmd *cobra.Command) *cobra.Command {
+	top := cmd
+	for top.HasParent() && top.Parent() != cmd.Root() {
+		top = top.Parent()
+	}
+	return top
+}
+
+// isWriteCommand reports whether cmd will make changes on the device
+func isWriteCommand(cmd *cobra.Command) bool {
+
+	top := topCommand(cmd)
+	switch {
+	case !writeCommands[top.Name()]:
+		return false
+	case top.Name() == "patch" && dryrun:
+		return false
+	case top.Name() == "apply" && planOnly:
+		return false
+	}
+	return true
+
+}
+
+// ensureActive makes sure changes don't go to the standby unit of a cluster.
+// Changes made on a standby unit would be lost or overwritten by the next
+// config sync.
+func ensureActive() {
+
+	switch standby {
+	case "allow":
+		return
+	case "refuse", "redirect":
+	default:
+		log.Fatalf("error: invalid --standby option %s; use refuse, redirect or allow\n", standby)
+	}
+
+	// only a device that says it is standby is held back, so a standalone
+	// device or one whose status can't be read still takes changes
+	err, isStandby := appliance.IsStandby(ctx)
+	if err != nil {
+		log.Printf("warning: can't read the failover status of %s, carrying on : %s\n", f5Host, err)
+		return
+	}
+	if !isStandby {
+		return
+	}
+
+	err, peer := appliance.ActivePeer(ctx)
+	if standby == "refuse" {
+		if err != nil {
+			log.Fatalf("error: %s is not the active unit - refusing to make changes\n", f5Host)
+		}
+		log.Fatalf("error: %s is not the active unit, %s (%s) is - refusing to make changes; use --standby=redirect to send them to the active unit\n", f5Host, peer.Name, peer.ManagementIP)
+	}
+	if err != nil {
+		log.Fatalf("error finding the active unit for %s : %s\n", f5Host, err)
+	}
+
+	redirect(peer)
+
+}
+
+// redirect points the appliance at the active unit. A server name or
+// fingerprint given for the standby won't match the certificate of the active
+// unit, so its own are taken from the inventory, and without an inventory
+// entry the redirect is refused if either is set.
+func redirect(peer *f5.LBDeviceState) {
+
+	host := peer.ManagementIP
+	found := false
+	if path := viper.GetString("inventory"); path != "" {
+		err, inv := readInventory(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if name, ok := inv.find(peer.Name, peer.ManagementIP); ok {
+			dev := inv.device(name)
+			host = dev.Device
+			serverName = dev.ServerName
+			fingerprints = splitFingerprints([]string{dev.Fingerprint})
+			if dev.CABundle != "" {
+				caBundle = dev.CABundle
+			}
+			if dev.Insecure != nil {
+				insecure = *dev.Insecure
+			}
+			found = true
+		}
+	}
+	if !found && (serverName != "" || len(fingerprints) > 0) {
+		log.Fatalf("error: %s is not the active unit, %s (%s) is, but the server name and fingerprint given are for %s - refusing to redirect; add %s to the inventory with its own tls options or run against it directly\n", f5Host, peer.Name, peer.ManagementIP, f5Host, peer.Name)
+	}
+
+	log.Printf("%s is not the active unit, redirecting to %s (%s)\n", f5Host, peer.Name, host)
+	f5Host = host
+	appliance = newAppliance(f5Host)
+
+}
+
+func printSyncStatus(s *f5.LBSyncStatus) {
+	log.Printf("sync status: %s - %s\n", s.Status, s.Summary)
+	for _, d := range s.Details {
+		log.Printf("  %s\n", d)
+	}
+}
+
+// syncConfig pushes the configuration to the device group and waits for the
+// group to report it is in sync
+func syncConfig(group string) {
+
+	if group == "" {
+		group = viper.GetString("sync_group")
+	}
+	if group == "" {
+		var err error
+		err, group = appliance.SyncGroup(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("syncing %s to device group %s\n", f5Host, group)
+	err := appliance.ConfigSync(ctx, group)
+	if err != nil {
+		log.Fatalf("error syncing to device group %s : %s\n", group, err)
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+	err, _ = appliance.WaitForSync(wctx, 2*time.Second, printSyncStatus)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+}
+
+// afterCommand runs once a command has completed successfully
+func afterCommand(cmd *cobra.Command) {
+	if viper.GetBool("sync") && isWriteCommand(cmd) && cmd != syncCmd {
+		syncConfig("")
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// validation collects the problems found in a stack
+type validation struct {
+	problems []string
+	// the objects of the stack, by kind and full path
+	inStack map[stackRef]*stackObject
+	// references looked up on the device, so each is only looked up once
+	onDevice map[stackRef]bool
+	// every profile and monitor on the device, of any type, once needed
+	profiles map[string]string
+	monitors map[string]string
+}
+
+func (v *validation) add(obj *stackObject, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf("%s[%d] %s: ", obj.kind.name, obj.index, obj.fullPath)+fmt.Sprintf(format, args...))
+}
+
+// load reads every object of the stack, noting those that can't be read or
+// are given more than once
+func (v *validation) load(stack *LBStack) []*stackObject {
+
+	objects := make([]*stackObject, 0)
+	for _, kind := range stackKinds {
+		for count, body := range kind.items(stack) {
+			err, obj := parseStackObject(kind, count, body)
+			if err != nil {
+				v.problems = append(v.problems, err.Error())
+				continue
+			}
+			key := stackRef{kind.name, obj.fullPath}
+			if first, ok := v.inStack[key]; ok {
+				v.add(obj, "duplicate of %s[%d]", kind.name, first.index)
+				continue
+			}
+			v.inStack[key] = obj
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+
+}
+
+// pathProblems checks that a name and partition agree with a full path
+func pathProblems(fullPath string, name string, partition string) []string {
+
+	problems := make([]string, 0)
+	if !strings.HasPrefix(fullPath, "/") || strings.Count(fullPath, "/") < 2 {
+		return append(problems, fmt.Sprintf("fullPath %s has no partition, eg. /Common/%s", fullPath, strings.Trim(fullPath, "/")))
+	}
+	if partition != "" && !strings.HasPrefix(fullPath, "/"+partition+"/") {
+		problems = append(problems, fmt.Sprintf("partition %s doesn't match fullPath %s", partition, fullPath))
+	}
+	if name != "" && path.Base(fullPath) != name {
+		problems = append(problems, fmt.Sprintf("name %s doesn't match fullPath %s", name, fullPath))
+	}
+	return problems
+
+}
+
+// checkPaths checks the names of an object, and of the members of a pool
+func (v *validation) checkPaths(obj *stackObject) {
+
+	id := identity{}
+	json.Unmarshal(obj.body, &id)
+	for _, p := range pathProblems(obj.fullPath, id.Name, id.Partition) {
+		v.add(obj, "%s", p)
+	}
+
+	if obj.kind.name != "pool" {
+		return
+	}
+	pool := f5.LBPool{}
+	json.Unmarshal(obj.body, &pool)
+	for _, m := range pool.Members {
+		if m.FullPath == "" {
+			continue
+		}
+		for _, p := range pathProblems(m.FullPath, m.Name, m.Partition) {
+			v.add(obj, "member %s", p)
+		}
+	}
+
+}
+
+// exists looks up an object on the device
+func (v *validation) exists(ref stackRef) bool {
+
+	if found, ok := v.onDevice[ref]; ok {
+		return found
+	}
+
+	var err error
+	switch ref.kind {
+	case "profile":
+		if v.profiles == nil {
+			err, v.profiles = appliance.ProfilePaths(ctx)
+			if err != nil {
+				log.Fatalf("error listing profiles: %s\n", err)
+			}
+		}
+		return v.profiles[ref.path] != ""
+	case "monitor":
+		if v.monitors == nil {
+			err, v.monitors = appliance.MonitorPaths(ctx)
+			if err != nil {
+				log.Fatalf("error listing monitors: %s\n", err)
+			}
+		}
+		return v.monitors[ref.path] != ""
+	case "cert", "key":
+		parts := strings.SplitN(strings.TrimPrefix(ref.path, "/"), "/", 2)
+		if len(parts) < 2 {
+			return false
+		}
+		if ref.kind == "cert" {
+			err, _ = appliance.GetCertificate(ctx, parts[0], parts[1])
+		} else {
+			err, _ = appliance.GetKey(ctx, parts[0], parts[1])
+		}
+	default:
+		for _, kind := range stackKinds {
+			if kind.name == ref.kind {
+				err, _ = kind.show(ctx, ref.path)
+			}
+		}
+	}
+
+	if err != nil && !f5.IsNotFound(err) {
+		log.Fatalf("error looking up %s %s : %s\n", ref.kind, ref.path, err)
+	}
+	v.onDevice[ref] = err == nil
+	return err == nil
+
+}
+
+// createdNodes returns the nodes of the members of a pool that give an address
+// or are named by one. The device creates those nodes with the pool, so they
+// needn't exist already.
+func createdNodes(obj *stackObject) map[string]bool {
+
+	created := make(map[string]bool)
+	if obj.kind.name != "pool" {
+		return created
+	}
+	pool := f5.LBPool{}
+	json.Unmarshal(obj.body, &pool)
+	for _, m := range pool.Members {
+		node := memberNode(&m, pool.Partition)
+		if m.Address != "" || isAddress(path.Base(node)) {
+			created[node] = true
+		}
+	}
+	return created
+
+}
+
+// checkRefs makes sure every object an object refers to is in the stack or
+// on the device
+func (v *validation) checkRefs(obj *stackObject) {
+
+	created := createdNodes(obj)
+	seen := make(map[stackRef]bool)
+	for _, ref := range obj.refs {
+		if ref.kind == "node" && created[ref.path] {
+			continue
+		}
+		inStack := v.inStack[ref] != nil
+		if obj.kind.name == "virtual" && (ref.kind == "client-ssl" || ref.kind == "server-ssl") {
+			// a virtual doesn't say what type of profile it is using
+			inStack = v.inStack[stackRef{"client-ssl", ref.path}] != nil || v.inStack[stackRef{"server-ssl", ref.path}] != nil
+			ref = stackRef{"profile", ref.path}
+		}
+		if seen[ref] || inStack {
+			continue
+		}
+		seen[ref] = true
+		found := v.exists(ref)
+		if !found && obj.kind.name == "rule" && ref.kind == "pool" {
+			// a rule can name a pool in Common without its partition
+			common := stackRef{"pool", qualify(path.Base(ref.path), "Common")}
+			found = v.inStack[common] != nil || v.exists(common)
+		}
+		if !found {
+			v.add(obj, "%s %s is not in the stack or on the device", ref.kind, ref.path)
+		}
+	}
+
+}
+
+// routeDomain returns the route domain of an address such as 10.1.1.1%6,
+// /DMZ/10.1.1.1%6:80 or 0.0.0.0%6/0, with "" for the default route domain
+func routeDomain(addr string) string {
+	i := strings.Index(addr, "%")
+	if i < 0 {
+		return ""
+	}
+	j := i + 1
+	for j < len(addr) && addr[j] >= '0' && addr[j] <= '9' {
+		j++
+	}
+	if rd := addr[i+1 : j]; rd != "0" {
+		return rd
+	}
+	return ""
+}
+
+func describeRouteDomain(rd string) string {
+	if rd == "" {
+		return "the default route domain"
+	}
+	return "route domain " + rd
+}
+
+// isAddress reports whether a name is an address, with or without a route domain
+func isAddress(name string) bool {
+	if i := strings.Index(name, "%"); i >= 0 {
+		name = name[:i]
+	}
+	return net.ParseIP(name) != nil
+}
+
+// checkRouteDomains makes sure nodes, pool members and virtuals that work
+// together use the same route domain
+func (v *validation) checkRouteDomains(objects []*stackObject) {
+
+	nodes := make(map[string]string)
+	pools := make(map[string]string)
+	// pools already reported for members in more than one route domain
+	mixed := make(map[string]bool)
+
+	// stackKinds lists nodes before pools and pools before virtuals
+	for _, obj := range objects {
+		switch obj.kind.name {
+		case "node":
+			node := f5.LBNode{}
+			json.Unmarshal(obj.body, &node)
+			rd := routeDomain(node.Address)
+			if name := path.Base(obj.fullPath); isAddress(name) && routeDomain(name) != rd {
+				v.add(obj, "name is in %s but address %s is in %s", describeRouteDomain(routeDomain(name)), node.Address, describeRouteDomain(rd))
+			}
+			nodes[obj.fullPath] = rd
+
+		case "pool":
+			pool := f5.LBPool{}
+			json.Unmarshal(obj.body, &pool)
+			rds := make(map[string]bool)
+			for _, m := range pool.Members {
+				nodePath := memberNode(&m, pool.Partition)
+				nameRD := routeDomain(path.Base(nodePath))
+				rd := nameRD
+				if m.Address != "" {
+					rd = routeDomain(m.Address)
+					if isAddress(path.Base(nodePath)) && nameRD != rd {
+						v.add(obj, "member %s is in %s but its address %s is in %s", m.Name, describeRouteDomain(nameRD), m.Address, describeRouteDomain(rd))
+					}
+				}
+				if nodeRD, ok := nodes[nodePath]; ok && nodeRD != rd {
+					v.add(obj, "member %s is in %s but node %s is in %s", m.Name, describeRouteDomain(rd), nodePath, describeRouteDomain(nodeRD))
+				}
+				rds[rd] = true
+			}
+			found := make([]string, 0, len(rds))
+			for rd := range rds {
+				pools[obj.fullPath] = rd
+				found = append(found, describeRouteDomain(rd))
+			}
+			if len(rds) > 1 {
+				sort.Strings(found)
+				v.add(obj, "members are in more than one route domain: %s", strings.Join(found, ", "))
+				mixed[obj.fullPath] = true
+			}
+
+		case "virtual":
+			virtual := f5.LBVirtual{}
+			json.Unmarshal(obj.body, &virtual)
+			rd := routeDomain(virtual.Destination)
+			if virtual.Source != "" && routeDomain(virtual.Source) != rd {
+				v.add(obj, "source %s is in %s but destination %s is in %s", virtual.Source, describeRouteDomain(routeDomain(virtual.Source)), virtual.Destination, describeRouteDomain(rd))
+			}
+			pool := qualify(virtual.Pool, virtual.Partition)
+			if poolRD, ok := pools[pool]; ok && poolRD != rd && !mixed[pool] {
+				v.add(obj, "destination %s is in %s but the members of pool %s are in %s", virtual.Destination, describeRouteDomain(rd), pool, describeRouteDomain(poolRD))
+			}
+		}
+	}
+
+}
+
+// validateStack checks a stack file without changing anything: every reference
+// must be to an object in the stack or on the device, names must match their
+// full paths and route domains must agree. Exits non-zero with every problem.
+func validateStack() {
+
+	stack := readStack()
+	v := &validation{
+		inStack:  make(map[stackRef]*stackObject),
+		onDevice: make(map[stackRef]bool),
+	}
+
+	objects := v.load(&stack)
+	for _, obj := range objects {
+		v.checkPaths(obj)
+		v.checkRefs(obj)
+	}
+	v.checkRouteDomains(objects)
+
+	if len(v.problems) == 0 {
+		fmt.Printf("stack %s is valid: %d objects\n", f5Input, len(objects))
+		return
+	}
+	for _, p := range v.problems {
+		fmt.Println(p)
+	}
+	log.Fatalf("error: %d problems in stack %s\n", len(v.problems), f5Input)
+
+}
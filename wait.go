@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// waitFor blocks until an object is in the state given with --state and
+// --enabled, logging its status as it changes. Giving up after --wait-timeout is
+// fatal.
+func waitFor(obj f5.LBObjectRef) {
+
+	want := f5.WaitState{Availability: waitState, Enabled: waitEnabled}
+	wctx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	err, _ := appliance.WaitFor(wctx, obj, want, waitInterval, func(s *f5.LBObjectStatus) {
+		log.Printf("%s: %s\n", obj, s)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s is %s\n", obj, want)
+
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rabbitt/f5er/f5"
+)
+
+// how often to sample when watching without an --interval
+const defaultWatchInterval = 2 * time.Second
+
+// statistics shown when watching each kind of object, unless --columns is given
+var defaultWatchColumns = map[string][]string{
+	"virtual":     {"clientside.curConns", "clientside.totConns", "clientside.bitsIn", "clientside.bitsOut"},
+	"pool":        {"serverside.curConns", "totRequests", "serverside.bitsIn", "serverside.bitsOut"},
+	"poolmember":  {"serverside.curConns", "totRequests", "serverside.bitsIn", "serverside.bitsOut"},
+	"node":        {"serverside.curConns", "totRequests", "serverside.bitsIn", "serverside.bitsOut"},
+	"rule":        {"totalExecutions", "failures", "aborts", "avgCycles"},
+	"cpu":         {"fiveSecAvgUser", "fiveSecAvgSystem", "fiveSecAvgIowait", "fiveSecAvgIdle"},
+	"memory":      {"memoryUsed", "memoryFree", "memoryTotal"},
+	"disk":        {"vgInUse", "vgFree", "size"},
+	"throughput":  {"Current", "Average", "Max"},
+	"connections": {"Current", "Average", "Max"},
+}
+
+// watchRow is one object in the watch table
+type watchRow struct {
+	kind  string
+	name  string
+	stats map[string]f5.MetricRate
+}
+
+func watchRowName(m f5.Metric) string {
+	name := m.Object
+	if m.Partition != "" {
+		name = "/" + m.Partition + "/" + m.Object
+	}
+	if m.Member != "" {
+		name += " " + m.Member
+	}
+	return name
+}
+
+// watchValue is what a cell shows - the rate of a counter, the value of a gauge
+func watchValue(r f5.MetricRate) float64 {
+	if r.Type == f5.CounterMetric {
+		return r.PerSecond
+	}
+	return r.Value
+}
+
+// humanise shortens big numbers, eg. 1234567 to 1.2M
+func humanise(v float64) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 1e12:
+		return fmt.Sprintf("%.1fT", v/1e12)
+	case abs >= 1e9:
+		return fmt.Sprintf("%.1fG", v/1e9)
+	case abs >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case abs >= 1e3:
+		return fmt.Sprintf("%.1fK", v/1e3)
+	case v == math.Trunc(v):
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprintf("%.1f", v)
+	}
+}
+
+// watchTable groups rates into a row for each object, busiest first by the
+// statistic to sort by, and returns the statistics to show as columns
+func watchTable(rates []f5.MetricRate) ([]string, string, []*watchRow) {
+
+	rows := make([]*watchRow, 0)
+	byName := make(map[string]*watchRow)
+	kinds := make([]string, 0)
+	for _, r := range rates {
+		key := r.Kind + "|" + watchRowName(r.Metric)
+		row, ok := byName[key]
+		if !ok {
+			row = &watchRow{kind: r.Kind, name: watchRowName(r.Metric), stats: make(map[string]f5.MetricRate)}
+			byName[key] = row
+			rows = append(rows, row)
+			if !contains(kinds, r.Kind) {
+				kinds = append(kinds, r.Kind)
+			}
+		}
+		row.stats[r.Name] = r
+	}
+
+	columns := watchColumns
+	if len(columns) == 0 {
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			for _, column := range defaultWatchColumns[kind] {
+				if !contains(columns, column) {
+					columns = append(columns, column)
+				}
+			}
+		}
+	}
+	sortBy := watchSort
+	if sortBy == "" && len(columns) > 0 {
+		sortBy = columns[0]
+	}
+	if sortBy != "" && !contains(columns, sortBy) {
+		columns = append([]string{sortBy}, columns...)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, aok := rows[i].stats[sortBy]
+		b, bok := rows[j].stats[sortBy]
+		switch {
+		case aok != bok:
+			// objects without the statistic go last
+			return aok
+		case aok && watchValue(a) != watchValue(b):
+			return watchValue(a) > watchValue(b)
+		case rows[i].kind != rows[j].kind:
+			return rows[i].kind < rows[j].kind
+		default:
+			return rows[i].name < rows[j].name
+		}
+	})
+	return columns, sortBy, rows
+
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// renderWatch writes the watch table for one pair of samples
+func renderWatch(out io.Writer, rates []f5.MetricRate, interval time.Duration) {
+
+	columns, sortBy, rows := watchTable(rates)
+
+	// counters are shown as rates, so say so in the heading
+	headings := make([]string, len(columns))
+	for i, column := range columns {
+		headings[i] = column
+		for _, row := range rows {
+			if r, ok := row.stats[column]; ok {
+				if r.Type == f5.CounterMetric {
+					headings[i] += "/s"
+				}
+				break
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "%s  %s  every %s  sorted by %s  %d objects\n\n", appliance.Hostname, time.Now().Format("15:04:05"), interval, sortBy, len(rows))
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "KIND\tOBJECT\t%s\n", strings.Join(headings, "\t"))
+	for i, row := range rows {
+		if watchTop > 0 && i == watchTop {
+			break
+		}
+		cells := make([]string, len(columns))
+		for j, column := range columns {
+			cells[j] = "-"
+			if r, ok := row.stats[column]; ok {
+				cells[j] = humanise(watchValue(r))
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", row.kind, row.name, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+
+}
+
+// watchStats samples the statistics returned by get every --interval and
+// shows how they changed, refreshing the screen until stopped or printing
+// a table for each sample after the first with --samples
+func watchStats(get func(context.Context) (error, []f5.Metric)) {
+
+	if watchSamples == 1 {
+		log.Fatal("error: --samples must be at least 2 to compute rates")
+	}
+	// objects must not drop out of the table when idle
+	appliance.SetStatsShowZeroes(true)
+	interval := statsInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	err, prev := get(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for n := 1; watchSamples <= 0 || n < watchSamples; n++ {
+		time.Sleep(interval)
+		err, cur := get(ctx)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		if watchSamples <= 0 {
+			// clear the screen and start at the top
+			fmt.Print("\033[H\033[2J")
+		} else if n > 1 {
+			fmt.Println()
+		}
+		renderWatch(os.Stdout, f5.Rates(prev, cur), interval)
+		prev = cur
+	}
+
+}